@@ -28,6 +28,7 @@ type Cmd interface {
 }
 
 func (c *CF) Run(args []string) error {
+	args = globalArgs(args)
 	if len(args) == 0 {
 		c.Help.Short()
 		return errors.New("command required")
@@ -48,3 +49,15 @@ func (c *CF) Run(args []string) error {
 	c.Help.Short()
 	return errors.New("invalid command")
 }
+
+// globalArgs removes options that apply to all commands, such as --json,
+// which are handled before the command is run.
+func globalArgs(args []string) []string {
+	var out []string
+	for _, arg := range args {
+		if arg != "--json" {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
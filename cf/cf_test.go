@@ -78,6 +78,15 @@ var _ = Describe("CF", func() {
 			})
 		})
 
+		Context("when global options are provided", func() {
+			It("should remove them before running the command", func() {
+				cmd1.EXPECT().Match([]string{"some-cmd", "some-arg"}).Return(true)
+				cmd1.EXPECT().Run([]string{"some-cmd", "some-arg"})
+
+				Expect(cf.Run([]string{"--json", "some-cmd", "some-arg"})).To(Succeed())
+			})
+		})
+
 		Context("when no command is specified", func() {
 			It("should show the short usage and return an error", func() {
 				mockHelp.EXPECT().Short()
@@ -3,12 +3,17 @@ package cmd
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"strings"
 	"time"
 
+	"code.cloudfoundry.org/cflocal/config"
 	"code.cloudfoundry.org/cflocal/fs"
 	"code.cloudfoundry.org/cflocal/remote"
+	"code.cloudfoundry.org/cflocal/snapshot"
 	"github.com/buildpack/forge"
 	"github.com/buildpack/forge/app"
 	"github.com/buildpack/forge/engine"
@@ -23,21 +28,38 @@ const (
 type UI interface {
 	Prompt(prompt string) string
 	Output(format string, a ...interface{})
+	Result(result string, fields map[string]interface{})
 	Warn(format string, a ...interface{})
 	Error(err error)
 	Loading(message string, progress <-chan engine.Progress) error
+	Logs(source string) io.Writer
 }
 
 //go:generate mockgen -package mocks -destination mocks/remote_app.go code.cloudfoundry.org/cflocal/cf/cmd RemoteApp
 type RemoteApp interface {
 	Command(name string) (string, error)
-	Droplet(name string) (droplet io.ReadCloser, size int64, err error)
+	Details(name string) (*remote.AppDetails, error)
+	Droplet(name string, offset int64, etag string) (*remote.DropletDownload, error)
+	DropletChecksum(name string) (algorithm, checksum string, err error)
 	SetDroplet(name string, droplet io.Reader, size int64) error
 	Env(name string) (*remote.AppEnv, error)
 	SetEnv(name string, env map[string]string) error
 	Restart(name string) error
+	RollingRestart(name string) error
+	GUID(name string) (string, error)
+	Name(name string) (string, error)
+	Rename(name, newName string) error
+	Copy(name, newName string) (guid string, err error)
+	MoveRoutes(name, targetName string) error
+	Delete(name string) error
 	Services(name string) (forge.Services, error)
-	Forward(name string, services forge.Services) (forge.Services, *forge.ForwardDetails, error)
+	Forward(name string, services forge.Services, options *remote.ForwardOptions) (forge.Services, *remote.ForwardDetails, error)
+	StreamLogs(name string, stop <-chan struct{}) (<-chan remote.LogMessage, error)
+}
+
+//go:generate mockgen -package mocks -destination mocks/targets.go code.cloudfoundry.org/cflocal/cf/cmd Targets
+type Targets interface {
+	RemoteApp(target string) (RemoteApp, error)
 }
 
 //go:generate mockgen -package mocks -destination mocks/local_app.go code.cloudfoundry.org/cflocal/cf/cmd LocalApp
@@ -65,6 +87,16 @@ type Forwarder interface {
 	Forward(config *forge.ForwardConfig) (health <-chan string, done func(), id string, err error)
 }
 
+//go:generate mockgen -package mocks -destination mocks/tunneler.go code.cloudfoundry.org/cflocal/cf/cmd Tunneler
+type Tunneler interface {
+	Tunnel(details *remote.ForwardDetails, ip string) (health <-chan string, done func(), err error)
+}
+
+//go:generate mockgen -package mocks -destination mocks/stubber.go code.cloudfoundry.org/cflocal/cf/cmd Stubber
+type Stubber interface {
+	Stub(name, mode, target string) (url string, done func(), err error)
+}
+
 //go:generate mockgen -package mocks -destination mocks/image.go code.cloudfoundry.org/cflocal/cf/cmd Image
 type Image interface {
 	Pull(stack string) <-chan engine.Progress
@@ -74,9 +106,13 @@ type Image interface {
 type FS interface {
 	ReadFile(path string) (io.ReadCloser, int64, error)
 	WriteFile(path string) (io.WriteCloser, error)
+	AppendFile(path string) (file io.WriteCloser, size int64, err error)
+	Rename(oldPath, newPath string) error
+	Remove(path string) error
 	OpenFile(path string) (fs.ReadResetWriteCloser, int64, error)
 	Abs(path string) (string, error)
-	Watch(dir string, wait time.Duration) (change <-chan time.Time, done chan<- struct{}, err error)
+	Ignore(path string, patterns ...string) (*fs.Ignore, error)
+	Watch(dir string, wait time.Duration, ignore *fs.Ignore) (change <-chan time.Time, done chan<- struct{}, err error)
 }
 
 //go:generate mockgen -package mocks -destination mocks/help.go code.cloudfoundry.org/cflocal/cf/cmd Help
@@ -88,6 +124,27 @@ type Help interface {
 type Config interface {
 	Load() (*app.YAML, error)
 	Save(localYML *app.YAML) error
+	LoadOptions() (*config.Options, error)
+	SaveOptions(options *config.Options) error
+}
+
+//go:generate mockgen -package mocks -destination mocks/log_files.go code.cloudfoundry.org/cflocal/cf/cmd LogFiles
+type LogFiles interface {
+	Record(name string) (io.Closer, error)
+	Tail(name string, out io.Writer, follow bool, stop <-chan struct{}) error
+}
+
+//go:generate mockgen -package mocks -destination mocks/redactor.go code.cloudfoundry.org/cflocal/cf/cmd Redactor
+type Redactor interface {
+	Redact(secrets ...string)
+	Flush() error
+}
+
+//go:generate mockgen -package mocks -destination mocks/snapshots.go code.cloudfoundry.org/cflocal/cf/cmd Snapshots
+type Snapshots interface {
+	Save(info snapshot.Info, services forge.Services) error
+	Load(name string) (forge.Services, error)
+	List() ([]snapshot.Info, error)
 }
 
 func parseOptions(args []string, f func(name string, set *flag.FlagSet)) error {
@@ -120,20 +177,142 @@ func getAppConfig(name string, localYML *app.YAML) *forge.AppConfig {
 	return app
 }
 
-func getRemoteServices(app RemoteApp, serviceApp, forwardApp string) (forge.Services, *forge.ForwardDetails, error) {
-	var services forge.Services
+// stackName returns the name of the stack that a stack image provides, such
+// as cflinuxfs3 for packs/cflinuxfs3:build.
+func stackName(image string) string {
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func confirm(ui UI, prompt string) bool {
+	switch strings.ToLower(strings.TrimSpace(ui.Prompt(prompt + " [y/N]"))) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func selectRemoteApp(app RemoteApp, targets Targets, target string) (RemoteApp, error) {
+	if target == "" {
+		return app, nil
+	}
+	return targets.RemoteApp(target)
+}
 
+// getRemoteServices imports the service bindings of a remote app, or of a
+// services snapshot if serviceApp is @<snapshot>, scoped by the
+// remote_services options in local.yml. The secrets in the imported
+// credentials are passed to the redactor, if provided.
+func getRemoteServices(app RemoteApp, localConfig Config, snapshots Snapshots, redactor Redactor, serviceApp, forwardApp string) (forge.Services, *remote.ForwardDetails, error) {
+	if _, ok := snapshotName(forwardApp); ok {
+		return nil, nil, errors.New("services snapshots may only be used with -s")
+	}
+	if serviceApp == "" {
+		serviceApp, _ = remote.SplitInstance(forwardApp)
+	}
 	if serviceApp == "" {
-		serviceApp = forwardApp
+		return nil, nil, nil
 	}
-	if serviceApp != "" {
-		var err error
-		if services, err = app.Services(serviceApp); err != nil {
+	options, err := localConfig.LoadOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	scope, redactFields := serviceScope(options)
+
+	var services forge.Services
+	if name, ok := snapshotName(serviceApp); ok {
+		services, err = snapshots.Load(name)
+	} else {
+		services, err = app.Services(serviceApp)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	services = scope.Filter(services)
+	var details *remote.ForwardDetails
+	if forwardApp != "" {
+		if services, details, err = forwardServices(app, localConfig, options, forwardApp, services); err != nil {
 			return nil, nil, err
 		}
 	}
-	if forwardApp != "" {
-		return app.Forward(forwardApp, services)
+	if err := scope.Override(services); err != nil {
+		return nil, nil, err
+	}
+	if redactor != nil {
+		redactor.Redact(remote.Secrets(services, redactFields...)...)
+	}
+	return services, details, nil
+}
+
+// snapshotName returns the name of the services snapshot referred to by
+// @<snapshot>.
+func snapshotName(ref string) (name string, ok bool) {
+	if !strings.HasPrefix(ref, "@") {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, "@"), true
+}
+
+func serviceScope(options *config.Options) (scope *remote.ServiceScope, redactFields []string) {
+	if options.RemoteServices == nil {
+		return nil, nil
+	}
+	return &remote.ServiceScope{
+		Allow:     options.RemoteServices.Allow,
+		Overrides: options.RemoteServices.Overrides,
+	}, options.RemoteServices.Redact
+}
+
+// forwardServices forwards the services using the forwarding rules and
+// ports in local.yml, and saves any newly assigned ports to local.yml.
+func forwardServices(app RemoteApp, localConfig Config, options *config.Options, forwardApp string, services forge.Services) (forge.Services, *remote.ForwardDetails, error) {
+	ports := map[string]uint{}
+	for key, port := range options.ForwardedPorts {
+		ports[key] = port
+	}
+	services, details, err := app.Forward(forwardApp, services, &remote.ForwardOptions{
+		Rules:     forwardRules(options),
+		Ports:     ports,
+		Available: portAvailable,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ports) != len(options.ForwardedPorts) {
+		options.ForwardedPorts = ports
+		if err := localConfig.SaveOptions(options); err != nil {
+			return nil, nil, err
+		}
+	}
+	return services, details, nil
+}
+
+func portAvailable(port uint) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+func forwardRules(options *config.Options) map[string]*remote.ForwardRule {
+	rules := map[string]*remote.ForwardRule{}
+	for service, rule := range options.Forwarding {
+		rules[service] = &remote.ForwardRule{
+			Host:      rule.Host,
+			Port:      rule.Port,
+			Addresses: rule.Addresses,
+		}
+		for _, forward := range rule.Forwards {
+			rules[service].Forwards = append(rules[service].Forwards, remote.ForwardAddress{
+				From: forward.From,
+				To:   forward.To,
+			})
+		}
 	}
-	return services, nil, nil
+	return rules
 }
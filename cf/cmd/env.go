@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+)
+
+const redacted = "<redacted>"
+
+// diffEnv describes the changes from the old to the new environment
+// variables, sorted by name. Values are redacted, since they often contain
+// credentials.
+func diffEnv(old, new map[string]string) []string {
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	for _, name := range names {
+		oldValue, inOld := old[name]
+		newValue, inNew := new[name]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("+ %s=%s", name, redacted))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("- %s", name))
+		case oldValue != newValue:
+			changes = append(changes, fmt.Sprintf("~ %s=%s", name, redacted))
+		}
+	}
+	return changes
+}
+
+// mergeEnv returns the old environment variables updated with the new ones.
+func mergeEnv(old, new map[string]string) map[string]string {
+	env := map[string]string{}
+	for name, value := range old {
+		env[name] = value
+	}
+	for name, value := range new {
+		env[name] = value
+	}
+	return env
+}
@@ -58,6 +58,11 @@ func (e *Export) Run(args []string) error {
 	if err != nil {
 		return err
 	}
+	e.UI.Result("exported", map[string]interface{}{
+		"name":      options.name,
+		"reference": options.reference,
+		"image_id":  id,
+	})
 	if options.reference != "" {
 		e.UI.Output("Exported %s as %s with ID: %s", options.name, options.reference, id)
 	} else {
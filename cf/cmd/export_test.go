@@ -100,6 +100,11 @@ var _ = Describe("Export", func() {
 			Expect(cmd.Run([]string{"export", "some-app", "-r", "some-reference"})).To(Succeed())
 			Expect(droplet.Result()).To(BeEmpty())
 			Expect(mockUI.Out).To(gbytes.Say("Exported some-app as some-reference with ID: some-id"))
+			Expect(mockUI.Results["exported"]).To(Equal(map[string]interface{}{
+				"name":      "some-app",
+				"reference": "some-reference",
+				"image_id":  "some-id",
+			}))
 			Expect(mockUI.Progress).To(Receive(Equal(mockProgress{Value: "some-progress"})))
 		})
 
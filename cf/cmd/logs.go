@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+
+	"code.cloudfoundry.org/cflocal/remote"
+)
+
+type Logs struct {
+	UI        UI
+	RemoteApp RemoteApp
+	Targets   Targets
+	LogFiles  LogFiles
+	Help      Help
+	Exit      <-chan struct{}
+}
+
+type logsOptions struct {
+	ref    string
+	target string
+	local  bool
+	follow bool
+}
+
+func (l *Logs) Match(args []string) bool {
+	return len(args) > 0 && args[0] == "logs"
+}
+
+func (l *Logs) Run(args []string) error {
+	options, err := l.options(args)
+	if err != nil {
+		l.Help.Short()
+		return err
+	}
+	if options.local {
+		return l.LogFiles.Tail(options.ref, l.UI.Logs("local"), options.follow, l.Exit)
+	}
+	if options.follow {
+		return errors.New("--follow is only valid with --local")
+	}
+	remoteApp, err := selectRemoteApp(l.RemoteApp, l.Targets, options.target)
+	if err != nil {
+		return err
+	}
+	messages, err := remoteApp.StreamLogs(options.ref, l.Exit)
+	if err != nil {
+		return err
+	}
+	l.UI.Output("Streaming logs for %s...", options.ref)
+	printRemoteLogs(l.UI.Logs("remote"), remote.AppName(options.ref), messages)
+	return nil
+}
+
+func (*Logs) options(args []string) (*logsOptions, error) {
+	options := &logsOptions{}
+
+	return options, parseOptions(args, func(name string, set *flag.FlagSet) {
+		options.ref = name
+		set.StringVar(&options.target, "target", "", "")
+		set.BoolVar(&options.local, "local", false, "")
+		set.BoolVar(&options.follow, "follow", false, "")
+	})
+}
+
+// printRemoteLogs writes remote log messages until the messages channel is
+// closed. Each message is prefixed by its source, in a different color than
+// the prefix used for local container output.
+func printRemoteLogs(out io.Writer, name string, messages <-chan remote.LogMessage) {
+	for message := range messages {
+		prefix := color.CyanString("[%s/%s/%s]", name, message.SourceType, message.InstanceID)
+		if message.Error {
+			prefix = color.MagentaString("[%s/%s/%s]", name, message.SourceType, message.InstanceID)
+		}
+		fmt.Fprintf(out, "%s %s\n", prefix, message.Message)
+	}
+}
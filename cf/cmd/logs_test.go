@@ -0,0 +1,109 @@
+package cmd_test
+
+import (
+	"io"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	. "code.cloudfoundry.org/cflocal/cf/cmd"
+	"code.cloudfoundry.org/cflocal/cf/cmd/mocks"
+	sharedmocks "code.cloudfoundry.org/cflocal/mocks"
+	"code.cloudfoundry.org/cflocal/remote"
+)
+
+var _ = Describe("Logs", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockUI        *sharedmocks.MockUI
+		mockRemoteApp *mocks.MockRemoteApp
+		mockTargets   *mocks.MockTargets
+		mockLogFiles  *mocks.MockLogFiles
+		mockHelp      *mocks.MockHelp
+		exit          chan struct{}
+		cmd           *Logs
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockUI = sharedmocks.NewMockUI()
+		mockRemoteApp = mocks.NewMockRemoteApp(mockCtrl)
+		mockTargets = mocks.NewMockTargets(mockCtrl)
+		mockLogFiles = mocks.NewMockLogFiles(mockCtrl)
+		mockHelp = mocks.NewMockHelp(mockCtrl)
+		exit = make(chan struct{})
+		cmd = &Logs{
+			UI:        mockUI,
+			RemoteApp: mockRemoteApp,
+			Targets:   mockTargets,
+			LogFiles:  mockLogFiles,
+			Help:      mockHelp,
+			Exit:      exit,
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("#Match", func() {
+		It("should return true when the first argument is logs", func() {
+			Expect(cmd.Match([]string{"logs"})).To(BeTrue())
+			Expect(cmd.Match([]string{"not-logs"})).To(BeFalse())
+			Expect(cmd.Match([]string{})).To(BeFalse())
+			Expect(cmd.Match(nil)).To(BeFalse())
+		})
+	})
+
+	Describe("#Run", func() {
+		It("should stream the remote app's logs until the plugin exits", func() {
+			messages := make(chan remote.LogMessage, 2)
+			messages <- remote.LogMessage{SourceType: "APP/PROC/WEB", InstanceID: "0", Message: "some-message"}
+			messages <- remote.LogMessage{SourceType: "STG", InstanceID: "1", Message: "some-error", Error: true}
+			close(messages)
+			mockRemoteApp.EXPECT().StreamLogs("some-org/some-space/some-app", (<-chan struct{})(exit)).Return(messages, nil)
+
+			Expect(cmd.Run([]string{"logs", "some-org/some-space/some-app"})).To(Succeed())
+			Expect(mockUI.Out).To(gbytes.Say("Streaming logs for some-org/some-space/some-app..."))
+			Expect(mockUI.Out).To(gbytes.Say(`some-app/APP/PROC/WEB/0.* some-message`))
+			Expect(mockUI.Out).To(gbytes.Say(`some-app/STG/1.* some-error`))
+		})
+
+		Context("when a target is specified", func() {
+			It("should stream logs from the app on that target", func() {
+				targetApp := mocks.NewMockRemoteApp(mockCtrl)
+				messages := make(chan remote.LogMessage)
+				close(messages)
+				mockTargets.EXPECT().RemoteApp("some-target").Return(targetApp, nil)
+				targetApp.EXPECT().StreamLogs("some-app", gomock.Any()).Return(messages, nil)
+
+				Expect(cmd.Run([]string{"logs", "some-app", "--target", "some-target"})).To(Succeed())
+			})
+		})
+
+		Context("when --local is specified", func() {
+			It("should print the local app's most recent log file", func() {
+				mockLogFiles.EXPECT().Tail("some-app", gomock.Any(), false, (<-chan struct{})(exit)).Do(
+					func(_ string, out io.Writer, _ bool, _ <-chan struct{}) {
+						io.WriteString(out, "some-log-line\n")
+					},
+				)
+
+				Expect(cmd.Run([]string{"logs", "some-app", "--local"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("some-log-line"))
+			})
+
+			It("should follow the local app's log files when --follow is specified", func() {
+				mockLogFiles.EXPECT().Tail("some-app", gomock.Any(), true, (<-chan struct{})(exit))
+
+				Expect(cmd.Run([]string{"logs", "some-app", "--local", "--follow"})).To(Succeed())
+			})
+		})
+
+		It("should return an error when --follow is specified without --local", func() {
+			Expect(cmd.Run([]string{"logs", "some-app", "--follow"})).To(MatchError("--follow is only valid with --local"))
+		})
+	})
+})
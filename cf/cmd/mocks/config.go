@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	config "code.cloudfoundry.org/cflocal/config"
 	app "github.com/buildpack/forge/app"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -46,6 +47,19 @@ func (mr *MockConfigMockRecorder) Load() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockConfig)(nil).Load))
 }
 
+// LoadOptions mocks base method
+func (m *MockConfig) LoadOptions() (*config.Options, error) {
+	ret := m.ctrl.Call(m, "LoadOptions")
+	ret0, _ := ret[0].(*config.Options)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadOptions indicates an expected call of LoadOptions
+func (mr *MockConfigMockRecorder) LoadOptions() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadOptions", reflect.TypeOf((*MockConfig)(nil).LoadOptions))
+}
+
 // Save mocks base method
 func (m *MockConfig) Save(arg0 *app.YAML) error {
 	ret := m.ctrl.Call(m, "Save", arg0)
@@ -57,3 +71,15 @@ func (m *MockConfig) Save(arg0 *app.YAML) error {
 func (mr *MockConfigMockRecorder) Save(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockConfig)(nil).Save), arg0)
 }
+
+// SaveOptions mocks base method
+func (m *MockConfig) SaveOptions(arg0 *config.Options) error {
+	ret := m.ctrl.Call(m, "SaveOptions", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOptions indicates an expected call of SaveOptions
+func (mr *MockConfigMockRecorder) SaveOptions(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOptions", reflect.TypeOf((*MockConfig)(nil).SaveOptions), arg0)
+}
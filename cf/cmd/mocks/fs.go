@@ -48,6 +48,38 @@ func (mr *MockFSMockRecorder) Abs(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Abs", reflect.TypeOf((*MockFS)(nil).Abs), arg0)
 }
 
+// AppendFile mocks base method
+func (m *MockFS) AppendFile(arg0 string) (io.WriteCloser, int64, error) {
+	ret := m.ctrl.Call(m, "AppendFile", arg0)
+	ret0, _ := ret[0].(io.WriteCloser)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AppendFile indicates an expected call of AppendFile
+func (mr *MockFSMockRecorder) AppendFile(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendFile", reflect.TypeOf((*MockFS)(nil).AppendFile), arg0)
+}
+
+// Ignore mocks base method
+func (m *MockFS) Ignore(arg0 string, arg1 ...string) (*fs.Ignore, error) {
+	varargs := []interface{}{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Ignore", varargs...)
+	ret0, _ := ret[0].(*fs.Ignore)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Ignore indicates an expected call of Ignore
+func (mr *MockFSMockRecorder) Ignore(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	varargs := append([]interface{}{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ignore", reflect.TypeOf((*MockFS)(nil).Ignore), varargs...)
+}
+
 // OpenFile mocks base method
 func (m *MockFS) OpenFile(arg0 string) (fs.ReadResetWriteCloser, int64, error) {
 	ret := m.ctrl.Call(m, "OpenFile", arg0)
@@ -76,9 +108,33 @@ func (mr *MockFSMockRecorder) ReadFile(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFile", reflect.TypeOf((*MockFS)(nil).ReadFile), arg0)
 }
 
+// Remove mocks base method
+func (m *MockFS) Remove(arg0 string) error {
+	ret := m.ctrl.Call(m, "Remove", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Remove indicates an expected call of Remove
+func (mr *MockFSMockRecorder) Remove(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockFS)(nil).Remove), arg0)
+}
+
+// Rename mocks base method
+func (m *MockFS) Rename(arg0, arg1 string) error {
+	ret := m.ctrl.Call(m, "Rename", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rename indicates an expected call of Rename
+func (mr *MockFSMockRecorder) Rename(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rename", reflect.TypeOf((*MockFS)(nil).Rename), arg0, arg1)
+}
+
 // Watch mocks base method
-func (m *MockFS) Watch(arg0 string, arg1 time.Duration) (<-chan time.Time, chan<- struct{}, error) {
-	ret := m.ctrl.Call(m, "Watch", arg0, arg1)
+func (m *MockFS) Watch(arg0 string, arg1 time.Duration, arg2 *fs.Ignore) (<-chan time.Time, chan<- struct{}, error) {
+	ret := m.ctrl.Call(m, "Watch", arg0, arg1, arg2)
 	ret0, _ := ret[0].(<-chan time.Time)
 	ret1, _ := ret[1].(chan<- struct{})
 	ret2, _ := ret[2].(error)
@@ -86,8 +142,8 @@ func (m *MockFS) Watch(arg0 string, arg1 time.Duration) (<-chan time.Time, chan<
 }
 
 // Watch indicates an expected call of Watch
-func (mr *MockFSMockRecorder) Watch(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockFS)(nil).Watch), arg0, arg1)
+func (mr *MockFSMockRecorder) Watch(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockFS)(nil).Watch), arg0, arg1, arg2)
 }
 
 // WriteFile mocks base method
@@ -48,18 +48,69 @@ func (mr *MockRemoteAppMockRecorder) Command(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Command", reflect.TypeOf((*MockRemoteApp)(nil).Command), arg0)
 }
 
+// Copy mocks base method
+func (m *MockRemoteApp) Copy(arg0, arg1 string) (string, error) {
+	ret := m.ctrl.Call(m, "Copy", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Copy indicates an expected call of Copy
+func (mr *MockRemoteAppMockRecorder) Copy(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Copy", reflect.TypeOf((*MockRemoteApp)(nil).Copy), arg0, arg1)
+}
+
+// Delete mocks base method
+func (m *MockRemoteApp) Delete(arg0 string) error {
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockRemoteAppMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRemoteApp)(nil).Delete), arg0)
+}
+
+// Details mocks base method
+func (m *MockRemoteApp) Details(arg0 string) (*remote.AppDetails, error) {
+	ret := m.ctrl.Call(m, "Details", arg0)
+	ret0, _ := ret[0].(*remote.AppDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Details indicates an expected call of Details
+func (mr *MockRemoteAppMockRecorder) Details(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Details", reflect.TypeOf((*MockRemoteApp)(nil).Details), arg0)
+}
+
 // Droplet mocks base method
-func (m *MockRemoteApp) Droplet(arg0 string) (io.ReadCloser, int64, error) {
-	ret := m.ctrl.Call(m, "Droplet", arg0)
-	ret0, _ := ret[0].(io.ReadCloser)
-	ret1, _ := ret[1].(int64)
+func (m *MockRemoteApp) Droplet(arg0 string, arg1 int64, arg2 string) (*remote.DropletDownload, error) {
+	ret := m.ctrl.Call(m, "Droplet", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*remote.DropletDownload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Droplet indicates an expected call of Droplet
+func (mr *MockRemoteAppMockRecorder) Droplet(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Droplet", reflect.TypeOf((*MockRemoteApp)(nil).Droplet), arg0, arg1, arg2)
+}
+
+// DropletChecksum mocks base method
+func (m *MockRemoteApp) DropletChecksum(arg0 string) (string, string, error) {
+	ret := m.ctrl.Call(m, "DropletChecksum", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
-// Droplet indicates an expected call of Droplet
-func (mr *MockRemoteAppMockRecorder) Droplet(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Droplet", reflect.TypeOf((*MockRemoteApp)(nil).Droplet), arg0)
+// DropletChecksum indicates an expected call of DropletChecksum
+func (mr *MockRemoteAppMockRecorder) DropletChecksum(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropletChecksum", reflect.TypeOf((*MockRemoteApp)(nil).DropletChecksum), arg0)
 }
 
 // Env mocks base method
@@ -76,17 +127,67 @@ func (mr *MockRemoteAppMockRecorder) Env(arg0 interface{}) *gomock.Call {
 }
 
 // Forward mocks base method
-func (m *MockRemoteApp) Forward(arg0 string, arg1 forge.Services) (forge.Services, *forge.ForwardDetails, error) {
-	ret := m.ctrl.Call(m, "Forward", arg0, arg1)
+func (m *MockRemoteApp) Forward(arg0 string, arg1 forge.Services, arg2 *remote.ForwardOptions) (forge.Services, *remote.ForwardDetails, error) {
+	ret := m.ctrl.Call(m, "Forward", arg0, arg1, arg2)
 	ret0, _ := ret[0].(forge.Services)
-	ret1, _ := ret[1].(*forge.ForwardDetails)
+	ret1, _ := ret[1].(*remote.ForwardDetails)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
 // Forward indicates an expected call of Forward
-func (mr *MockRemoteAppMockRecorder) Forward(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Forward", reflect.TypeOf((*MockRemoteApp)(nil).Forward), arg0, arg1)
+func (mr *MockRemoteAppMockRecorder) Forward(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Forward", reflect.TypeOf((*MockRemoteApp)(nil).Forward), arg0, arg1, arg2)
+}
+
+// GUID mocks base method
+func (m *MockRemoteApp) GUID(arg0 string) (string, error) {
+	ret := m.ctrl.Call(m, "GUID", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GUID indicates an expected call of GUID
+func (mr *MockRemoteAppMockRecorder) GUID(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GUID", reflect.TypeOf((*MockRemoteApp)(nil).GUID), arg0)
+}
+
+// MoveRoutes mocks base method
+func (m *MockRemoteApp) MoveRoutes(arg0, arg1 string) error {
+	ret := m.ctrl.Call(m, "MoveRoutes", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MoveRoutes indicates an expected call of MoveRoutes
+func (mr *MockRemoteAppMockRecorder) MoveRoutes(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveRoutes", reflect.TypeOf((*MockRemoteApp)(nil).MoveRoutes), arg0, arg1)
+}
+
+// Name mocks base method
+func (m *MockRemoteApp) Name(arg0 string) (string, error) {
+	ret := m.ctrl.Call(m, "Name", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Name indicates an expected call of Name
+func (mr *MockRemoteAppMockRecorder) Name(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockRemoteApp)(nil).Name), arg0)
+}
+
+// Rename mocks base method
+func (m *MockRemoteApp) Rename(arg0, arg1 string) error {
+	ret := m.ctrl.Call(m, "Rename", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rename indicates an expected call of Rename
+func (mr *MockRemoteAppMockRecorder) Rename(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rename", reflect.TypeOf((*MockRemoteApp)(nil).Rename), arg0, arg1)
 }
 
 // Restart mocks base method
@@ -101,6 +202,18 @@ func (mr *MockRemoteAppMockRecorder) Restart(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restart", reflect.TypeOf((*MockRemoteApp)(nil).Restart), arg0)
 }
 
+// RollingRestart mocks base method
+func (m *MockRemoteApp) RollingRestart(arg0 string) error {
+	ret := m.ctrl.Call(m, "RollingRestart", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RollingRestart indicates an expected call of RollingRestart
+func (mr *MockRemoteAppMockRecorder) RollingRestart(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollingRestart", reflect.TypeOf((*MockRemoteApp)(nil).RollingRestart), arg0)
+}
+
 // Services mocks base method
 func (m *MockRemoteApp) Services(arg0 string) (forge.Services, error) {
 	ret := m.ctrl.Call(m, "Services", arg0)
@@ -137,3 +250,16 @@ func (m *MockRemoteApp) SetEnv(arg0 string, arg1 map[string]string) error {
 func (mr *MockRemoteAppMockRecorder) SetEnv(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEnv", reflect.TypeOf((*MockRemoteApp)(nil).SetEnv), arg0, arg1)
 }
+
+// StreamLogs mocks base method
+func (m *MockRemoteApp) StreamLogs(arg0 string, arg1 <-chan struct{}) (<-chan remote.LogMessage, error) {
+	ret := m.ctrl.Call(m, "StreamLogs", arg0, arg1)
+	ret0, _ := ret[0].(<-chan remote.LogMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StreamLogs indicates an expected call of StreamLogs
+func (mr *MockRemoteAppMockRecorder) StreamLogs(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamLogs", reflect.TypeOf((*MockRemoteApp)(nil).StreamLogs), arg0, arg1)
+}
@@ -1,64 +1,218 @@
 package cmd
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/cflocal/remote"
 )
 
 type Pull struct {
 	UI        UI
 	RemoteApp RemoteApp
+	Targets   Targets
 	FS        FS
 	Help      Help
 	Config    Config
 }
 
+type pullOptions struct {
+	ref    string
+	target string
+}
+
 func (p *Pull) Match(args []string) bool {
 	return len(args) > 0 && args[0] == "pull"
 }
 
 func (p *Pull) Run(args []string) error {
-	name, err := p.options(args)
+	options, err := p.options(args)
 	if err != nil {
 		p.Help.Short()
 		return err
 	}
-	if err := p.saveDroplet(name); err != nil {
+	remoteApp, err := selectRemoteApp(p.RemoteApp, p.Targets, options.target)
+	if err != nil {
+		return err
+	}
+	details, err := remoteApp.Details(options.ref)
+	if err != nil {
+		return err
+	}
+	name := details.Name
+	if err := p.saveDroplet(remoteApp, options.ref, name); err != nil {
+		return err
+	}
+	if err := p.updateLocalYML(remoteApp, options.ref, name); err != nil {
 		return err
 	}
-	if err := p.updateLocalYML(name); err != nil {
+	if err := p.saveStack(name, details.Stack); err != nil {
 		return err
 	}
 	p.UI.Output("Successfully downloaded: %s", name)
+	p.UI.Result("pulled", map[string]interface{}{
+		"name":    name,
+		"droplet": fmt.Sprintf("./%s.droplet", name),
+	})
 	return nil
 }
 
-func (p *Pull) saveDroplet(name string) error {
-	droplet, size, err := p.RemoteApp.Droplet(name)
+func (p *Pull) saveDroplet(remoteApp RemoteApp, ref, name string) error {
+	path := fmt.Sprintf("./%s.droplet", name)
+	partial := path + ".partial"
+	file, droplet, err := p.openPartial(remoteApp, ref, partial)
 	if err != nil {
 		return err
 	}
-	defer droplet.Close()
-	file, err := p.FS.WriteFile(fmt.Sprintf("./%s.droplet", name))
+	err = p.downloadDroplet(remoteApp, ref, file, droplet)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return err
 	}
+	if err := p.verifyDroplet(remoteApp, ref, partial); err != nil {
+		p.FS.Remove(partial)
+		return err
+	}
+	if err := p.FS.Rename(partial, path); err != nil {
+		return err
+	}
+	p.FS.Remove(partial + ".etag")
+	return nil
+}
+
+// openPartial opens the partial droplet and requests the rest of the droplet.
+// The partial droplet is only resumed if the droplet still has the ETag that
+// is saved next to it. Otherwise, it is replaced with the whole droplet.
+func (p *Pull) openPartial(remoteApp RemoteApp, ref, partial string) (io.WriteCloser, *remote.DropletDownload, error) {
+	file, offset, err := p.FS.AppendFile(partial)
+	if err != nil {
+		return nil, nil, err
+	}
+	etag := ""
+	if offset > 0 {
+		etag = p.readETag(partial + ".etag")
+	}
+	droplet, err := remoteApp.Droplet(ref, offset, etag)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if droplet.Offset != offset {
+		file.Close()
+		if err := p.FS.Remove(partial); err != nil {
+			droplet.Close()
+			return nil, nil, err
+		}
+		if file, _, err = p.FS.AppendFile(partial); err != nil {
+			droplet.Close()
+			return nil, nil, err
+		}
+	}
+	if droplet.ETag != etag {
+		if err := p.writeETag(partial+".etag", droplet.ETag); err != nil {
+			file.Close()
+			droplet.Close()
+			return nil, nil, err
+		}
+	}
+	return file, droplet, nil
+}
+
+func (p *Pull) downloadDroplet(remoteApp RemoteApp, ref string, file io.Writer, droplet *remote.DropletDownload) error {
+	return withProgress(p.UI, "Downloading droplet", droplet.Offset, droplet.Size, func(count *int64) error {
+		for attempt := 1; ; attempt++ {
+			_, err := io.Copy(&countingWriter{file, count}, droplet)
+			droplet.Close()
+			if err == nil && droplet.Size >= 0 && atomic.LoadInt64(count) < droplet.Size {
+				err = io.ErrUnexpectedEOF
+			}
+			if err == nil || !isTransient(err) || attempt > transferRetries {
+				return err
+			}
+			offset := atomic.LoadInt64(count)
+			if droplet, err = remoteApp.Droplet(ref, offset, droplet.ETag); err != nil {
+				return err
+			}
+			if droplet.Offset != offset {
+				droplet.Close()
+				return errors.New("droplet changed during download")
+			}
+		}
+	})
+}
+
+func (p *Pull) readETag(path string) string {
+	file, _, err := p.FS.ReadFile(path)
+	if err != nil {
+		return ""
+	}
 	defer file.Close()
-	if _, err := io.CopyN(file, droplet, size); err != nil && err != io.EOF {
+	etag, err := ioutil.ReadAll(file)
+	if err != nil {
+		return ""
+	}
+	return string(etag)
+}
+
+func (p *Pull) writeETag(path, etag string) error {
+	file, err := p.FS.WriteFile(path)
+	if err != nil {
 		return err
 	}
+	if _, err := io.WriteString(file, etag); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (p *Pull) verifyDroplet(remoteApp RemoteApp, ref, path string) error {
+	algorithm, checksum, err := remoteApp.DropletChecksum(ref)
+	if err != nil {
+		return err
+	}
+	var digest hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "sha256":
+		digest = sha256.New()
+	case "sha1":
+		digest = sha1.New()
+	default:
+		return nil
+	}
+	droplet, _, err := p.FS.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	defer droplet.Close()
+	if _, err := io.Copy(digest, droplet); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(digest.Sum(nil)); sum != strings.ToLower(checksum) {
+		return fmt.Errorf("droplet %s checksum mismatch: expected %s, got %s", algorithm, checksum, sum)
+	}
 	return nil
 }
 
-func (p *Pull) updateLocalYML(name string) error {
+func (p *Pull) updateLocalYML(remoteApp RemoteApp, ref, name string) error {
 	localYML, err := p.Config.Load()
 	if err != nil {
 		return err
 	}
 	app := getAppConfig(name, localYML)
 
-	env, err := p.RemoteApp.Env(name)
+	env, err := remoteApp.Env(ref)
 	if err != nil {
 		return err
 	}
@@ -66,7 +220,7 @@ func (p *Pull) updateLocalYML(name string) error {
 	app.RunningEnv = env.Running
 	app.Env = env.App
 
-	command, err := p.RemoteApp.Command(name)
+	command, err := remoteApp.Command(ref)
 	if err != nil {
 		return err
 	}
@@ -78,8 +232,28 @@ func (p *Pull) updateLocalYML(name string) error {
 	return nil
 }
 
-func (*Pull) options(args []string) (appName string, err error) {
-	return appName, parseOptions(args, func(name string, _ *flag.FlagSet) {
-		appName = name
+// saveStack records the stack that the pulled droplet was built for, so
+// that push can warn when the droplet is pushed to an app on another stack.
+func (p *Pull) saveStack(name, stack string) error {
+	localOptions, err := p.Config.LoadOptions()
+	if err != nil {
+		return err
+	}
+	if localOptions.DropletStacks[name] == stack {
+		return nil
+	}
+	if localOptions.DropletStacks == nil {
+		localOptions.DropletStacks = map[string]string{}
+	}
+	localOptions.DropletStacks[name] = stack
+	return p.Config.SaveOptions(localOptions)
+}
+
+func (*Pull) options(args []string) (*pullOptions, error) {
+	options := &pullOptions{}
+
+	return options, parseOptions(args, func(name string, set *flag.FlagSet) {
+		options.ref = name
+		set.StringVar(&options.target, "target", "", "")
 	})
 }
@@ -1,6 +1,8 @@
 package cmd_test
 
 import (
+	"errors"
+
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -8,10 +10,12 @@ import (
 
 	. "code.cloudfoundry.org/cflocal/cf/cmd"
 	"code.cloudfoundry.org/cflocal/cf/cmd/mocks"
+	"code.cloudfoundry.org/cflocal/config"
 	sharedmocks "code.cloudfoundry.org/cflocal/mocks"
 	"code.cloudfoundry.org/cflocal/remote"
 	"github.com/buildpack/forge"
 	"github.com/buildpack/forge/app"
+	"github.com/buildpack/forge/engine"
 )
 
 var _ = Describe("Pull", func() {
@@ -22,6 +26,8 @@ var _ = Describe("Pull", func() {
 		mockFS        *mocks.MockFS
 		mockHelp      *mocks.MockHelp
 		mockConfig    *mocks.MockConfig
+		details       *remote.AppDetails
+		localOptions  *config.Options
 		cmd           *Pull
 	)
 
@@ -32,6 +38,9 @@ var _ = Describe("Pull", func() {
 		mockFS = mocks.NewMockFS(mockCtrl)
 		mockHelp = mocks.NewMockHelp(mockCtrl)
 		mockConfig = mocks.NewMockConfig(mockCtrl)
+		details = &remote.AppDetails{Name: "some-app", Stack: "some-stack"}
+		localOptions = &config.Options{DropletStacks: map[string]string{"some-app": "some-stack"}}
+		mockConfig.EXPECT().LoadOptions().Return(localOptions, nil).AnyTimes()
 		cmd = &Pull{
 			UI:        mockUI,
 			RemoteApp: mockRemoteApp,
@@ -87,8 +96,12 @@ var _ = Describe("Pull", func() {
 					},
 				},
 			}
-			mockRemoteApp.EXPECT().Droplet("some-app").Return(droplet, int64(100), nil)
-			mockFS.EXPECT().WriteFile("./some-app.droplet").Return(file, nil)
+			mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+			mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil)
+			mockRemoteApp.EXPECT().Droplet("some-app", int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12}, nil)
+			mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("", "", nil)
+			mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+			mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
 			mockConfig.EXPECT().Load().Return(oldLocalYML, nil)
 			mockRemoteApp.EXPECT().Env("some-app").Return(env, nil)
 			mockRemoteApp.EXPECT().Command("some-app").Return("some-command", nil)
@@ -98,6 +111,234 @@ var _ = Describe("Pull", func() {
 			Expect(file.Result()).To(Equal("some-droplet"))
 			Expect(droplet.Result()).To(BeEmpty())
 			Expect(mockUI.Out).To(gbytes.Say("Successfully downloaded: some-app"))
+			Expect(mockUI.Results["pulled"]).To(Equal(map[string]interface{}{
+				"name":    "some-app",
+				"droplet": "./some-app.droplet",
+			}))
+		})
+
+		Context("when the app is referenced by org and space", func() {
+			It("should save the droplet and env vars using the app name", func() {
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				file := sharedmocks.NewMockBuffer("")
+				localYML := &app.YAML{}
+				mockRemoteApp.EXPECT().Details("some-org/some-space/some-app").Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil)
+				mockRemoteApp.EXPECT().Droplet("some-org/some-space/some-app", int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12}, nil)
+				mockRemoteApp.EXPECT().DropletChecksum("some-org/some-space/some-app").Return("", "", nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockRemoteApp.EXPECT().Env("some-org/some-space/some-app").Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command("some-org/some-space/some-app").Return("some-command", nil)
+				mockConfig.EXPECT().Save(&app.YAML{
+					Applications: []*forge.AppConfig{{Name: "some-app", Command: "some-command"}},
+				})
+
+				Expect(cmd.Run([]string{"pull", "some-org/some-space/some-app"})).To(Succeed())
+				Expect(file.Result()).To(Equal("some-droplet"))
+				Expect(mockUI.Out).To(gbytes.Say("Successfully downloaded: some-app"))
+			})
+		})
+
+		Context("when the app is referenced by GUID", func() {
+			It("should save the droplet and env vars using the app name", func() {
+				guid := "8b0e3a5c-1d2f-4e6a-9b7c-0d1e2f3a4b5c"
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				file := sharedmocks.NewMockBuffer("")
+				mockRemoteApp.EXPECT().Details(guid).Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil)
+				mockRemoteApp.EXPECT().Droplet(guid, int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12}, nil)
+				mockRemoteApp.EXPECT().DropletChecksum(guid).Return("", "", nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockRemoteApp.EXPECT().Env(guid).Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command(guid).Return("some-command", nil)
+				mockConfig.EXPECT().Save(&app.YAML{
+					Applications: []*forge.AppConfig{{Name: "some-app", Command: "some-command"}},
+				})
+
+				Expect(cmd.Run([]string{"pull", guid})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Successfully downloaded: some-app"))
+			})
+		})
+
+		Context("when the droplet was built for a different stack", func() {
+			It("should record the stack of the droplet", func() {
+				details.Stack = "some-other-stack"
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				file := sharedmocks.NewMockBuffer("")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil)
+				mockRemoteApp.EXPECT().Droplet("some-app", int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12}, nil)
+				mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("", "", nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command("some-app").Return("some-command", nil)
+				mockConfig.EXPECT().Save(gomock.Any())
+				mockConfig.EXPECT().SaveOptions(&config.Options{
+					DropletStacks: map[string]string{"some-app": "some-other-stack"},
+				})
+
+				Expect(cmd.Run([]string{"pull", "some-app"})).To(Succeed())
+			})
+		})
+
+		Context("when a partial droplet was already downloaded", func() {
+			It("should resume the download from the end of the partial droplet", func() {
+				droplet := sharedmocks.NewMockBuffer("droplet")
+				file := sharedmocks.NewMockBuffer("")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(5), nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet.partial.etag").Return(sharedmocks.NewMockBuffer(`"some-etag"`), int64(11), nil)
+				mockRemoteApp.EXPECT().Droplet("some-app", int64(5), `"some-etag"`).Return(&remote.DropletDownload{ReadCloser: droplet, Offset: 5, Size: 12, ETag: `"some-etag"`}, nil)
+				mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("", "", nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command("some-app").Return("some-command", nil)
+				mockConfig.EXPECT().Save(gomock.Any())
+
+				Expect(cmd.Run([]string{"pull", "some-app"})).To(Succeed())
+				Expect(file.Result()).To(Equal("droplet"))
+				Expect(mockUI.Out).To(gbytes.Say("Loading: Downloading droplet"))
+				var progress engine.Progress
+				Expect(mockUI.Progress).To(Receive(&progress))
+				Expect(progress.Status()).To(Equal("[====================] 12 B / 12 B"))
+			})
+
+			It("should replace the partial droplet when the droplet has changed", func() {
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				staleFile := sharedmocks.NewMockBuffer("")
+				file := sharedmocks.NewMockBuffer("")
+				etagFile := sharedmocks.NewMockBuffer("")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				gomock.InOrder(
+					mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(staleFile, int64(20), nil),
+					mockFS.EXPECT().ReadFile("./some-app.droplet.partial.etag").Return(sharedmocks.NewMockBuffer(`"some-old-etag"`), int64(15), nil),
+					mockRemoteApp.EXPECT().Droplet("some-app", int64(20), `"some-old-etag"`).Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12, ETag: `"some-etag"`}, nil),
+					mockFS.EXPECT().Remove("./some-app.droplet.partial"),
+					mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil),
+					mockFS.EXPECT().WriteFile("./some-app.droplet.partial.etag").Return(etagFile, nil),
+				)
+				mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("", "", nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command("some-app").Return("some-command", nil)
+				mockConfig.EXPECT().Save(gomock.Any())
+
+				Expect(cmd.Run([]string{"pull", "some-app"})).To(Succeed())
+				Expect(staleFile.Result()).To(BeEmpty())
+				Expect(file.Result()).To(Equal("some-droplet"))
+				Expect(etagFile.Result()).To(Equal(`"some-etag"`))
+			})
+
+			It("should replace the partial droplet when no ETag was saved for it", func() {
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				staleFile := sharedmocks.NewMockBuffer("")
+				file := sharedmocks.NewMockBuffer("")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				gomock.InOrder(
+					mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(staleFile, int64(5), nil),
+					mockFS.EXPECT().ReadFile("./some-app.droplet.partial.etag").Return(nil, int64(0), errors.New("some-error")),
+					mockRemoteApp.EXPECT().Droplet("some-app", int64(5), "").Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12}, nil),
+					mockFS.EXPECT().Remove("./some-app.droplet.partial"),
+					mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil),
+				)
+				mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("", "", nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command("some-app").Return("some-command", nil)
+				mockConfig.EXPECT().Save(gomock.Any())
+
+				Expect(cmd.Run([]string{"pull", "some-app"})).To(Succeed())
+				Expect(file.Result()).To(Equal("some-droplet"))
+			})
+		})
+
+		Context("when the download is interrupted", func() {
+			It("should resume the download from where it stopped", func() {
+				droplet1 := sharedmocks.NewMockBuffer("some-")
+				droplet2 := sharedmocks.NewMockBuffer("droplet")
+				file := sharedmocks.NewMockBuffer("")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil)
+				etagFile := sharedmocks.NewMockBuffer("")
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().Droplet("some-app", int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet1, Size: 12, ETag: `"some-etag"`}, nil),
+					mockFS.EXPECT().WriteFile("./some-app.droplet.partial.etag").Return(etagFile, nil),
+					mockRemoteApp.EXPECT().Droplet("some-app", int64(5), `"some-etag"`).Return(&remote.DropletDownload{ReadCloser: droplet2, Offset: 5, Size: 12, ETag: `"some-etag"`}, nil),
+				)
+				mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("", "", nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command("some-app").Return("some-command", nil)
+				mockConfig.EXPECT().Save(gomock.Any())
+
+				Expect(cmd.Run([]string{"pull", "some-app"})).To(Succeed())
+				Expect(file.Result()).To(Equal("some-droplet"))
+			})
+
+			It("should return an error when the droplet changes before the download resumes", func() {
+				droplet1 := sharedmocks.NewMockBuffer("some-")
+				droplet2 := sharedmocks.NewMockBuffer("some-other-droplet")
+				file := sharedmocks.NewMockBuffer("")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(file, int64(0), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().Droplet("some-app", int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet1, Size: 12, ETag: `"some-etag"`}, nil),
+					mockFS.EXPECT().WriteFile("./some-app.droplet.partial.etag").Return(sharedmocks.NewMockBuffer(""), nil),
+					mockRemoteApp.EXPECT().Droplet("some-app", int64(5), `"some-etag"`).Return(&remote.DropletDownload{ReadCloser: droplet2, Size: 18, ETag: `"some-other-etag"`}, nil),
+				)
+
+				Expect(cmd.Run([]string{"pull", "some-app"})).To(MatchError("droplet changed during download"))
+				Expect(droplet2.Result()).To(Equal("some-other-droplet"))
+			})
+		})
+
+		Context("when the droplet has a checksum", func() {
+			It("should verify the downloaded droplet", func() {
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				partial := sharedmocks.NewMockBuffer("some-droplet")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(sharedmocks.NewMockBuffer(""), int64(0), nil)
+				mockRemoteApp.EXPECT().Droplet("some-app", int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12}, nil)
+				mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("sha256", "d8e8fca2dc0f896fd7cb4cb0031ba249ad0d7c0d6e8a6b4e6b6d42e8e9d5f2b4", nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet.partial").Return(partial, int64(12), nil)
+				mockFS.EXPECT().Remove("./some-app.droplet.partial")
+
+				err := cmd.Run([]string{"pull", "some-app"})
+				Expect(err).To(MatchError(HavePrefix("droplet sha256 checksum mismatch")))
+				Expect(partial.Result()).To(BeEmpty())
+			})
+
+			It("should keep the droplet when the checksum matches", func() {
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				partial := sharedmocks.NewMockBuffer("some-droplet")
+				mockRemoteApp.EXPECT().Details("some-app").Return(details, nil)
+				mockFS.EXPECT().AppendFile("./some-app.droplet.partial").Return(sharedmocks.NewMockBuffer(""), int64(0), nil)
+				mockRemoteApp.EXPECT().Droplet("some-app", int64(0), "").Return(&remote.DropletDownload{ReadCloser: droplet, Size: 12}, nil)
+				mockRemoteApp.EXPECT().DropletChecksum("some-app").Return("sha1", "1b7a9d1547d5961a820bab8e4bbba04b0aa8503a", nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet.partial").Return(partial, int64(12), nil)
+				mockFS.EXPECT().Rename("./some-app.droplet.partial", "./some-app.droplet")
+				mockFS.EXPECT().Remove("./some-app.droplet.partial.etag")
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+				mockRemoteApp.EXPECT().Command("some-app").Return("some-command", nil)
+				mockConfig.EXPECT().Save(gomock.Any())
+
+				Expect(cmd.Run([]string{"pull", "some-app"})).To(Succeed())
+			})
 		})
 
 		// TODO: test when app isn't in local.yml
@@ -1,22 +1,35 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"path"
+
+	"code.cloudfoundry.org/cflocal/remote"
 )
 
 type Push struct {
 	UI        UI
 	RemoteApp RemoteApp
+	Targets   Targets
 	FS        FS
 	Help      Help
 	Config    Config
 }
 
 type pushOptions struct {
-	name      string
+	ref       string
+	target    string
+	strategy  string
 	keepState bool
 	pushEnv   bool
+	mergeEnv  bool
+	confirmed bool
+	dryRun    bool
 }
 
 func (p *Push) Match(args []string) bool {
@@ -30,46 +43,303 @@ func (p *Push) Run(args []string) error {
 		return err
 	}
 
-	if err := p.pushDroplet(options.name); err != nil {
+	remoteApp, err := selectRemoteApp(p.RemoteApp, p.Targets, options.target)
+	if err != nil {
+		return err
+	}
+	details, err := remoteApp.Details(options.ref)
+	if err != nil {
+		return err
+	}
+	name := details.Name
+	stack, err := p.dropletStack(name)
+	if err != nil {
+		return err
+	}
+	if options.dryRun {
+		return p.dryRun(remoteApp, options, name, stack, details)
+	}
+	if err := p.confirmTarget(options, stack, details); err != nil {
 		return err
 	}
+	var env map[string]string
 	if options.pushEnv {
-		if err := p.pushEnv(options.name); err != nil {
+		if env, err = p.planEnv(remoteApp, options, name); err != nil {
 			return err
 		}
 	}
-	if !options.keepState {
-		if err := p.RemoteApp.Restart(options.name); err != nil {
+	if options.strategy == "blue-green" {
+		err = p.blueGreen(remoteApp, options, name, env)
+	} else {
+		err = p.replace(remoteApp, options, name, env)
+	}
+	if err != nil {
+		return err
+	}
+	p.UI.Output("Successfully pushed: %s", name)
+	p.UI.Result("pushed", map[string]interface{}{
+		"name":      name,
+		"restarted": !options.keepState,
+		"strategy":  options.strategy,
+	})
+	return nil
+}
+
+func (p *Push) dryRun(remoteApp RemoteApp, options *pushOptions, name, stack string, details *remote.AppDetails) error {
+	dropletPath := fmt.Sprintf("./%s.droplet", name)
+	droplet, size, err := p.FS.ReadFile(dropletPath)
+	if err != nil {
+		return err
+	}
+	defer droplet.Close()
+	digest := sha256.New()
+	if _, err := io.Copy(digest, droplet); err != nil {
+		return err
+	}
+	checksum := hex.EncodeToString(digest.Sum(nil))
+	protected, err := p.protected(details)
+	if err != nil {
+		return err
+	}
+
+	p.UI.Output("Target: %s/%s/%s", details.Org, details.Space, details.Name)
+	p.UI.Output("Droplet: %s (%s, sha256:%s)", dropletPath, formatBytes(size), checksum)
+	if stackMatches(stack, details) {
+		p.UI.Output("Stack: %s", details.Stack)
+	} else {
+		p.UI.Warn("droplet was staged for %s, but %s uses %s", stack, details.Name, details.Stack)
+	}
+	if protected {
+		p.UI.Output("Space %s/%s is protected: pushing requires confirmation.", details.Org, details.Space)
+	}
+	if options.pushEnv {
+		if _, err := p.planEnv(remoteApp, options, name); err != nil {
 			return err
 		}
 	}
-	p.UI.Output("Successfully pushed: %s", options.name)
+	p.UI.Output("Dry run complete: nothing was pushed.")
+	p.UI.Result("dry-run", map[string]interface{}{
+		"org":         details.Org,
+		"space":       details.Space,
+		"name":        details.Name,
+		"droplet":     dropletPath,
+		"size":        size,
+		"sha256":      checksum,
+		"stack":       details.Stack,
+		"stack_match": stackMatches(stack, details),
+		"protected":   protected,
+	})
 	return nil
 }
 
-func (p *Push) pushDroplet(name string) error {
-	droplet, size, err := p.FS.ReadFile(fmt.Sprintf("./%s.droplet", name))
+// confirmTarget asks for confirmation before pushing a droplet to an app
+// that uses a different stack, or to an app in a protected space.
+func (p *Push) confirmTarget(options *pushOptions, stack string, details *remote.AppDetails) error {
+	if !stackMatches(stack, details) {
+		p.UI.Warn("droplet was staged for %s, but %s uses %s", stack, details.Name, details.Stack)
+		if !options.confirmed && !confirm(p.UI, "Push the droplet anyway?") {
+			return fmt.Errorf("push to %s stack was not confirmed", details.Stack)
+		}
+	}
+	protected, err := p.protected(details)
+	if err != nil {
+		return err
+	}
+	if protected && !options.confirmed && !confirm(p.UI, fmt.Sprintf("Push to protected space %s/%s?", details.Org, details.Space)) {
+		return fmt.Errorf("push to protected space %s/%s was not confirmed", details.Org, details.Space)
+	}
+	return nil
+}
+
+func (p *Push) protected(details *remote.AppDetails) (bool, error) {
+	localOptions, err := p.Config.LoadOptions()
+	if err != nil {
+		return false, err
+	}
+	space := details.Org + "/" + details.Space
+	for _, pattern := range localOptions.ProtectedSpaces {
+		matched, err := path.Match(pattern, space)
+		if err != nil {
+			return false, fmt.Errorf("invalid protected space pattern '%s': %s", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dropletStack returns the stack that the app's local droplet was built
+// for, which is recorded in local.yml when the droplet is pulled. Droplets
+// that are staged locally are built for BuildStack.
+func (p *Push) dropletStack(name string) (string, error) {
+	localOptions, err := p.Config.LoadOptions()
+	if err != nil {
+		return "", err
+	}
+	if stack, ok := localOptions.DropletStacks[name]; ok {
+		return stack, nil
+	}
+	return stackName(BuildStack), nil
+}
+
+func stackMatches(stack string, details *remote.AppDetails) bool {
+	return details.Stack == "" || details.Stack == stack
+}
+
+func (p *Push) replace(remoteApp RemoteApp, options *pushOptions, name string, env map[string]string) error {
+	if err := p.pushDroplet(remoteApp, options.ref, name); err != nil {
+		return err
+	}
+	if env != nil {
+		if err := remoteApp.SetEnv(options.ref, env); err != nil {
+			return err
+		}
+	}
+	switch {
+	case options.keepState:
+		return nil
+	case options.strategy == "rolling":
+		return remoteApp.RollingRestart(options.ref)
+	}
+	return remoteApp.Restart(options.ref)
+}
+
+// blueGreen renames the app to <name>-venerable, starts a copy of it with
+// the new droplet, and then moves the routes to the copy. The original app
+// is only deleted once the copy is running and routed.
+func (p *Push) blueGreen(remoteApp RemoteApp, options *pushOptions, name string, env map[string]string) error {
+	guid, err := remoteApp.GUID(options.ref)
+	if err != nil {
+		return err
+	}
+	if err := remoteApp.Rename(guid, name+"-venerable"); err != nil {
+		return err
+	}
+	newGUID, err := remoteApp.Copy(guid, name)
+	if err == nil {
+		err = p.pushDroplet(remoteApp, newGUID, name)
+	}
+	if err == nil && env != nil {
+		err = remoteApp.SetEnv(newGUID, env)
+	}
+	if err == nil {
+		err = remoteApp.Restart(newGUID)
+	}
+	if err == nil {
+		if err = remoteApp.MoveRoutes(guid, newGUID); err != nil {
+			p.restoreRoutes(remoteApp, newGUID, guid)
+		}
+	}
+	if err != nil {
+		p.rollback(remoteApp, guid, newGUID, name)
+		return err
+	}
+	return remoteApp.Delete(guid)
+}
+
+func (p *Push) restoreRoutes(remoteApp RemoteApp, newGUID, guid string) {
+	if err := remoteApp.MoveRoutes(newGUID, guid); err != nil {
+		p.UI.Warn("failed to restore routes: %s", err)
+	}
+}
+
+func (p *Push) rollback(remoteApp RemoteApp, guid, newGUID, name string) {
+	if newGUID != "" {
+		if err := remoteApp.Delete(newGUID); err != nil {
+			p.UI.Warn("failed to delete new app: %s", err)
+			return
+		}
+	}
+	if err := remoteApp.Rename(guid, name); err != nil {
+		p.UI.Warn("failed to restore app name: %s", err)
+	}
+}
+
+func (p *Push) pushDroplet(remoteApp RemoteApp, ref, name string) error {
+	dropletPath := fmt.Sprintf("./%s.droplet", name)
+	for attempt := 1; ; attempt++ {
+		err := p.uploadDroplet(remoteApp, ref, dropletPath)
+		if err == nil || !isTransient(err) || attempt > transferRetries {
+			return err
+		}
+		p.UI.Warn("droplet upload failed, retrying: %s", err)
+	}
+}
+
+func (p *Push) uploadDroplet(remoteApp RemoteApp, ref, dropletPath string) error {
+	droplet, size, err := p.FS.ReadFile(dropletPath)
 	if err != nil {
 		return err
 	}
 	defer droplet.Close()
-	return p.RemoteApp.SetDroplet(name, droplet, size)
+	return withProgress(p.UI, "Uploading droplet", 0, size, func(count *int64) error {
+		return remoteApp.SetDroplet(ref, &countingReader{droplet, count}, size)
+	})
 }
 
-func (p *Push) pushEnv(name string) error {
+// planEnv shows the changes that pushing the local.yml environment variables
+// would make to the remote app, and returns the resulting environment once
+// the changes are confirmed. It returns nil when there are no changes.
+func (p *Push) planEnv(remoteApp RemoteApp, options *pushOptions, name string) (map[string]string, error) {
 	localYML, err := p.Config.Load()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return p.RemoteApp.SetEnv(name, getAppConfig(name, localYML).Env)
+	remoteEnv, err := remoteApp.Env(options.ref)
+	if err != nil {
+		return nil, err
+	}
+	env := getAppConfig(name, localYML).Env
+	if options.mergeEnv {
+		env = mergeEnv(remoteEnv.App, env)
+	}
+	changes := diffEnv(remoteEnv.App, env)
+	if len(changes) == 0 {
+		p.UI.Output("Remote environment variables are already up to date.")
+		return nil, nil
+	}
+	p.UI.Output("Environment variable changes for %s:", options.ref)
+	for _, change := range changes {
+		p.UI.Output("  %s", change)
+	}
+	if options.dryRun {
+		return nil, nil
+	}
+	if !options.confirmed && !confirm(p.UI, "Push these environment variable changes?") {
+		return nil, errors.New("environment variable changes were not confirmed")
+	}
+	if env == nil {
+		env = map[string]string{}
+	}
+	return env, nil
 }
 
 func (*Push) options(args []string) (*pushOptions, error) {
 	options := &pushOptions{}
 
-	return options, parseOptions(args, func(name string, set *flag.FlagSet) {
-		options.name = name
+	if err := parseOptions(args, func(name string, set *flag.FlagSet) {
+		options.ref = name
+		set.StringVar(&options.target, "target", "", "")
+		set.StringVar(&options.strategy, "strategy", "", "")
 		set.BoolVar(&options.keepState, "k", false, "")
 		set.BoolVar(&options.pushEnv, "e", false, "")
-	})
+		set.BoolVar(&options.mergeEnv, "env-merge", false, "")
+		set.BoolVar(&options.confirmed, "y", false, "")
+		set.BoolVar(&options.dryRun, "dry-run", false, "")
+	}); err != nil {
+		return nil, err
+	}
+	if options.mergeEnv {
+		options.pushEnv = true
+	}
+	switch options.strategy {
+	case "", "rolling", "blue-green":
+	default:
+		return nil, fmt.Errorf("invalid strategy: %s", options.strategy)
+	}
+	if options.strategy != "" && options.keepState {
+		return nil, errors.New("-k cannot be used with --strategy")
+	}
+	return options, nil
 }
@@ -1,8 +1,10 @@
 package cmd_test
 
 import (
+	"errors"
 	"io"
 	"io/ioutil"
+	"net"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
@@ -11,9 +13,12 @@ import (
 
 	. "code.cloudfoundry.org/cflocal/cf/cmd"
 	"code.cloudfoundry.org/cflocal/cf/cmd/mocks"
+	"code.cloudfoundry.org/cflocal/config"
 	sharedmocks "code.cloudfoundry.org/cflocal/mocks"
+	"code.cloudfoundry.org/cflocal/remote"
 	"github.com/buildpack/forge"
 	"github.com/buildpack/forge/app"
+	"github.com/buildpack/forge/engine"
 )
 
 var _ = Describe("Push", func() {
@@ -21,9 +26,12 @@ var _ = Describe("Push", func() {
 		mockCtrl      *gomock.Controller
 		mockUI        *sharedmocks.MockUI
 		mockRemoteApp *mocks.MockRemoteApp
+		mockTargets   *mocks.MockTargets
 		mockFS        *mocks.MockFS
 		mockHelp      *mocks.MockHelp
 		mockConfig    *mocks.MockConfig
+		details       *remote.AppDetails
+		localOptions  *config.Options
 		cmd           *Push
 	)
 
@@ -31,12 +39,18 @@ var _ = Describe("Push", func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		mockUI = sharedmocks.NewMockUI()
 		mockRemoteApp = mocks.NewMockRemoteApp(mockCtrl)
+		mockTargets = mocks.NewMockTargets(mockCtrl)
 		mockFS = mocks.NewMockFS(mockCtrl)
 		mockHelp = mocks.NewMockHelp(mockCtrl)
 		mockConfig = mocks.NewMockConfig(mockCtrl)
+		details = &remote.AppDetails{Org: "some-org", Space: "some-space", Name: "some-app", Stack: "cflinuxfs3"}
+		localOptions = &config.Options{}
+		mockRemoteApp.EXPECT().Details(gomock.Any()).Return(details, nil).AnyTimes()
+		mockConfig.EXPECT().LoadOptions().Return(localOptions, nil).AnyTimes()
 		cmd = &Push{
 			UI:        mockUI,
 			RemoteApp: mockRemoteApp,
+			Targets:   mockTargets,
 			FS:        mockFS,
 			Help:      mockHelp,
 			Config:    mockConfig,
@@ -70,7 +84,9 @@ var _ = Describe("Push", func() {
 			}
 			mockConfig.EXPECT().Load().Return(localYML, nil)
 			mockFS.EXPECT().ReadFile("./some-app.droplet").Return(droplet, int64(100), nil)
+			mockUI.Reply["Push these environment variable changes? [y/N]"] = "y"
 			gomock.InOrder(
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{App: map[string]string{"other": "env"}}, nil),
 				mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(100)).Do(func(_ string, r io.Reader, _ int64) {
 					Expect(ioutil.ReadAll(r)).To(Equal([]byte("some-droplet")))
 				}),
@@ -79,7 +95,272 @@ var _ = Describe("Push", func() {
 			)
 			Expect(cmd.Run([]string{"push", "some-app", "-e"})).To(Succeed())
 			Expect(droplet.Result()).To(BeEmpty())
+			Expect(mockUI.Out).To(gbytes.Say(`Environment variable changes for some-app:\s+- other\s+\+ some=<redacted>`))
 			Expect(mockUI.Out).To(gbytes.Say("Successfully pushed: some-app"))
+			Expect(mockUI.Results["pushed"]).To(Equal(map[string]interface{}{
+				"name":      "some-app",
+				"restarted": true,
+				"strategy":  "",
+			}))
+		})
+
+		Context("when a target is specified", func() {
+			It("should push to the app on that target", func() {
+				targetApp := mocks.NewMockRemoteApp(mockCtrl)
+				droplet := sharedmocks.NewMockBuffer("some-droplet")
+				mockTargets.EXPECT().RemoteApp("some-target").Return(targetApp, nil)
+				targetApp.EXPECT().Details("some-org/some-space/some-app").Return(details, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(droplet, int64(100), nil)
+				gomock.InOrder(
+					targetApp.EXPECT().SetDroplet("some-org/some-space/some-app", gomock.Any(), int64(100)),
+					targetApp.EXPECT().Restart("some-org/some-space/some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-org/some-space/some-app", "--target", "some-target"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Successfully pushed: some-app"))
+				Expect(mockUI.Results["pushed"]).To(HaveKeyWithValue("name", "some-app"))
+			})
+		})
+
+		Context("when the upload is interrupted", func() {
+			It("should retry the upload from the start of the droplet", func() {
+				droplet1 := sharedmocks.NewMockBuffer("some-droplet")
+				droplet2 := sharedmocks.NewMockBuffer("some-droplet")
+				gomock.InOrder(
+					mockFS.EXPECT().ReadFile("./some-app.droplet").Return(droplet1, int64(12), nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(12)).Return(&net.OpError{Op: "write", Err: errors.New("some-error")}),
+					mockFS.EXPECT().ReadFile("./some-app.droplet").Return(droplet2, int64(12), nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(12)).Do(func(_ string, r io.Reader, _ int64) {
+						Expect(ioutil.ReadAll(r)).To(Equal([]byte("some-droplet")))
+					}),
+					mockRemoteApp.EXPECT().Restart("some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Warning: droplet upload failed, retrying: write: some-error"))
+				Expect(mockUI.Out).To(gbytes.Say("Successfully pushed: some-app"))
+
+				var progress engine.Progress
+				Expect(mockUI.Progress).To(Receive(&progress))
+				Expect(progress.Status()).To(HaveSuffix("/ 12 B"))
+			})
+		})
+
+		Context("when --env-merge is specified", func() {
+			It("should only add and change remote environment variables", func() {
+				localYML := &app.YAML{
+					Applications: []*forge.AppConfig{{Name: "some-app", Env: map[string]string{"a": "new", "b": "b"}}},
+				}
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{App: map[string]string{"a": "old", "c": "c"}}, nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().SetEnv("some-app", map[string]string{"a": "new", "b": "b", "c": "c"}),
+					mockRemoteApp.EXPECT().Restart("some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app", "--env-merge", "-y"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say(`~ a=<redacted>\s+\+ b=<redacted>`))
+				Expect(string(mockUI.Out.Contents())).NotTo(ContainSubstring("Push these environment variable changes"))
+				Expect(string(mockUI.Out.Contents())).NotTo(ContainSubstring("new"))
+			})
+		})
+
+		Context("when the environment variable changes are not confirmed", func() {
+			It("should return an error without pushing the droplet", func() {
+				localYML := &app.YAML{
+					Applications: []*forge.AppConfig{{Name: "some-app", Env: map[string]string{"a": "a"}}},
+				}
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+				mockUI.Reply["Push these environment variable changes? [y/N]"] = "n"
+
+				err := cmd.Run([]string{"push", "some-app", "-e"})
+				Expect(err).To(MatchError("environment variable changes were not confirmed"))
+			})
+		})
+
+		Context("when the remote environment variables are already up to date", func() {
+			It("should not prompt or set the environment variables", func() {
+				localYML := &app.YAML{
+					Applications: []*forge.AppConfig{{Name: "some-app", Env: map[string]string{"a": "a"}}},
+				}
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{App: map[string]string{"a": "a"}}, nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().Restart("some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app", "-e"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Remote environment variables are already up to date."))
+			})
+		})
+
+		Context("when --dry-run is specified", func() {
+			It("should show what would be pushed without changing the remote app", func() {
+				localYML := &app.YAML{
+					Applications: []*forge.AppConfig{{Name: "some-app", Env: map[string]string{"a": "a"}}},
+				}
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil)
+
+				Expect(cmd.Run([]string{"push", "some-app", "-e", "--dry-run"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Target: some-org/some-space/some-app"))
+				Expect(mockUI.Out).To(gbytes.Say(`Droplet: \./some-app\.droplet \(12 B, sha256:ad975ce6d6b9028d73b820b34568871d48d43ba0d602e845b56d5ccbb48de2ce\)`))
+				Expect(mockUI.Out).To(gbytes.Say("Stack: cflinuxfs3"))
+				Expect(mockUI.Out).To(gbytes.Say(`\+ a=<redacted>`))
+				Expect(mockUI.Out).To(gbytes.Say("Dry run complete: nothing was pushed."))
+				Expect(mockUI.Results["dry-run"]).To(HaveKeyWithValue("stack_match", true))
+				Expect(string(mockUI.Out.Contents())).NotTo(ContainSubstring("[y/N]"))
+			})
+
+			It("should warn when the remote app uses a different stack", func() {
+				details.Stack = "some-stack"
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+
+				Expect(cmd.Run([]string{"push", "some-app", "--dry-run"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Warning: droplet was staged for cflinuxfs3, but some-app uses some-stack"))
+				Expect(mockUI.Results["dry-run"]).To(HaveKeyWithValue("stack_match", false))
+			})
+		})
+
+		Context("when the droplet was pulled from an app on a different stack", func() {
+			It("should compare the remote app's stack with the droplet's stack", func() {
+				details.Stack = "some-stack"
+				localOptions.DropletStacks = map[string]string{"some-app": "some-stack"}
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(12))
+				mockRemoteApp.EXPECT().Restart("some-app")
+
+				Expect(cmd.Run([]string{"push", "some-app"})).To(Succeed())
+				Expect(mockUI.Out).NotTo(gbytes.Say("Warning"))
+			})
+		})
+
+		Context("when the remote app uses a different stack", func() {
+			It("should not push the droplet unless confirmed", func() {
+				details.Stack = "some-stack"
+
+				Expect(cmd.Run([]string{"push", "some-app"})).To(MatchError("push to some-stack stack was not confirmed"))
+				Expect(mockUI.Out).To(gbytes.Say("Warning: droplet was staged for cflinuxfs3, but some-app uses some-stack"))
+				Expect(mockUI.Out).To(gbytes.Say(`Push the droplet anyway\? \[y/N\]`))
+			})
+		})
+
+		Context("when the remote app is in a protected space", func() {
+			BeforeEach(func() {
+				localOptions.ProtectedSpaces = []string{"some-org/some-*"}
+			})
+
+			It("should push the droplet when confirmed", func() {
+				mockUI.Reply["Push to protected space some-org/some-space? [y/N]"] = "yes"
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().Restart("some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app"})).To(Succeed())
+			})
+
+			It("should not push the droplet unless confirmed", func() {
+				Expect(cmd.Run([]string{"push", "some-app"})).To(MatchError("push to protected space some-org/some-space was not confirmed"))
+			})
+		})
+
+		Context("when the rolling strategy is specified", func() {
+			It("should restart the app using a rolling deployment", func() {
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().SetDroplet("some-app", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().RollingRestart("some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app", "--strategy", "rolling"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Successfully pushed: some-app"))
+				Expect(mockUI.Results["pushed"]).To(HaveKeyWithValue("strategy", "rolling"))
+			})
+		})
+
+		Context("when the blue-green strategy is specified", func() {
+			It("should start a copy of the app with the droplet and then move the routes to it", func() {
+				localYML := &app.YAML{
+					Applications: []*forge.AppConfig{{Name: "some-app", Env: map[string]string{"some": "env"}}},
+				}
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().Env("some-app").Return(&remote.AppEnv{}, nil),
+					mockRemoteApp.EXPECT().GUID("some-app").Return("some-guid", nil),
+					mockRemoteApp.EXPECT().Rename("some-guid", "some-app-venerable"),
+					mockRemoteApp.EXPECT().Copy("some-guid", "some-app").Return("some-new-guid", nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-new-guid", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().SetEnv("some-new-guid", map[string]string{"some": "env"}),
+					mockRemoteApp.EXPECT().Restart("some-new-guid"),
+					mockRemoteApp.EXPECT().MoveRoutes("some-guid", "some-new-guid"),
+					mockRemoteApp.EXPECT().Delete("some-guid"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app", "-e", "-y", "--strategy", "blue-green"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Successfully pushed: some-app"))
+			})
+
+			It("should name the copy after the app when the app is referenced by GUID", func() {
+				guid := "8b0e3a5c-1d2f-4e6a-9b7c-0d1e2f3a4b5c"
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().GUID(guid).Return(guid, nil),
+					mockRemoteApp.EXPECT().Rename(guid, "some-app-venerable"),
+					mockRemoteApp.EXPECT().Copy(guid, "some-app").Return("some-new-guid", nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-new-guid", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().Restart("some-new-guid"),
+					mockRemoteApp.EXPECT().MoveRoutes(guid, "some-new-guid"),
+					mockRemoteApp.EXPECT().Delete(guid),
+				)
+				Expect(cmd.Run([]string{"push", guid, "--strategy", "blue-green"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("Successfully pushed: some-app"))
+			})
+
+			It("should restore the original app when the copy fails to start", func() {
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().GUID("some-app").Return("some-guid", nil),
+					mockRemoteApp.EXPECT().Rename("some-guid", "some-app-venerable"),
+					mockRemoteApp.EXPECT().Copy("some-guid", "some-app").Return("some-new-guid", nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-new-guid", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().Restart("some-new-guid").Return(errors.New("some-error")),
+					mockRemoteApp.EXPECT().Delete("some-new-guid"),
+					mockRemoteApp.EXPECT().Rename("some-guid", "some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app", "--strategy", "blue-green"})).To(MatchError("some-error"))
+			})
+
+			It("should move the routes back when the routes cannot be moved", func() {
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				gomock.InOrder(
+					mockRemoteApp.EXPECT().GUID("some-app").Return("some-guid", nil),
+					mockRemoteApp.EXPECT().Rename("some-guid", "some-app-venerable"),
+					mockRemoteApp.EXPECT().Copy("some-guid", "some-app").Return("some-new-guid", nil),
+					mockRemoteApp.EXPECT().SetDroplet("some-new-guid", gomock.Any(), int64(12)),
+					mockRemoteApp.EXPECT().Restart("some-new-guid"),
+					mockRemoteApp.EXPECT().MoveRoutes("some-guid", "some-new-guid").Return(errors.New("some-error")),
+					mockRemoteApp.EXPECT().MoveRoutes("some-new-guid", "some-guid"),
+					mockRemoteApp.EXPECT().Delete("some-new-guid"),
+					mockRemoteApp.EXPECT().Rename("some-guid", "some-app"),
+				)
+				Expect(cmd.Run([]string{"push", "some-app", "--strategy", "blue-green"})).To(MatchError("some-error"))
+			})
+		})
+
+		Context("when an invalid strategy is specified", func() {
+			It("should show the usage and return an error", func() {
+				mockHelp.EXPECT().Short()
+				Expect(cmd.Run([]string{"push", "some-app", "--strategy", "some-strategy"})).To(MatchError("invalid strategy: some-strategy"))
+			})
+		})
+
+		Context("when -k is used with a strategy", func() {
+			It("should show the usage and return an error", func() {
+				mockHelp.EXPECT().Short()
+				Expect(cmd.Run([]string{"push", "some-app", "-k", "--strategy", "rolling"})).To(MatchError("-k cannot be used with --strategy"))
+			})
 		})
 
 		// TODO: test without setting env or restarting
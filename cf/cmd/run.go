@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/buildpack/forge"
 	"github.com/buildpack/forge/engine"
+
+	"code.cloudfoundry.org/cflocal/remote"
 )
 
 type Run struct {
@@ -20,7 +23,13 @@ type Run struct {
 	Runner    Runner
 	Forwarder Forwarder
 	RemoteApp RemoteApp
+	Targets   Targets
 	Image     Image
+	LogFiles  LogFiles
+	Snapshots Snapshots
+	Redactor  Redactor
+	Stubber   Stubber
+	Tunneler  Tunneler
 	FS        FS
 	Help      Help
 	Config    Config
@@ -31,10 +40,12 @@ type runOptions struct {
 	appDir     string
 	serviceApp string
 	forwardApp string
+	target     string
 	ip         string
 	port       uint
 	watch      bool
 	term       bool
+	remoteLogs bool
 }
 
 func (r *Run) Match(args []string) bool {
@@ -56,8 +67,16 @@ func (r *Run) Run(args []string) error {
 			return err
 		}
 		if options.watch {
+			localOptions, err := r.Config.LoadOptions()
+			if err != nil {
+				return err
+			}
+			ignore, err := r.FS.Ignore(appDir, localOptions.Ignore...)
+			if err != nil {
+				return err
+			}
 			var done chan<- struct{}
-			restart, done, err = r.FS.Watch(appDir, time.Second)
+			restart, done, err = r.FS.Watch(appDir, time.Second, ignore)
 			if err != nil {
 				return err
 			}
@@ -71,6 +90,14 @@ func (r *Run) Run(args []string) error {
 		return errors.New("-w and -t may not be used together")
 	}
 
+	logsApp, _ := remote.SplitInstance(options.forwardApp)
+	if _, ok := snapshotName(options.serviceApp); logsApp == "" && !ok {
+		logsApp = options.serviceApp
+	}
+	if options.remoteLogs && logsApp == "" {
+		return errors.New("--remote-logs requires -s <app> or -f")
+	}
+
 	localYML, err := r.Config.Load()
 	if err != nil {
 		return err
@@ -84,13 +111,24 @@ func (r *Run) Run(args []string) error {
 	defer droplet.Close()
 
 	appConfig := getAppConfig(options.name, localYML)
-	remoteServices, forwardConfig, err := getRemoteServices(r.RemoteApp, options.serviceApp, options.forwardApp)
+	remoteApp, err := selectRemoteApp(r.RemoteApp, r.Targets, options.target)
+	if err != nil {
+		return err
+	}
+	// deferred first, so that output is flushed after all containers stop
+	defer r.Redactor.Flush()
+	remoteServices, forwardConfig, err := getRemoteServices(remoteApp, r.Config, r.Snapshots, r.Redactor, options.serviceApp, options.forwardApp)
 	if err != nil {
 		return err
 	}
 	if remoteServices != nil {
 		appConfig.Services = remoteServices
 	}
+	stubsDone, err := r.stubServices(appConfig.Services, forwardConfig)
+	if err != nil {
+		return err
+	}
+	defer stubsDone()
 
 	netConfig := &forge.NetworkConfig{
 		ContainerPort: "8080",
@@ -107,7 +145,7 @@ func (r *Run) Run(args []string) error {
 			AppName:       appConfig.Name,
 			Stack:         NetworkStack,
 			Color:         color.GreenString,
-			Details:       forwardConfig,
+			Details:       &forwardConfig.ForwardDetails,
 			ContainerPort: netConfig.ContainerPort,
 			HostIP:        netConfig.HostIP,
 			HostPort:      netConfig.HostPort,
@@ -120,13 +158,42 @@ func (r *Run) Run(args []string) error {
 		if err := waitForHealthy(health); err != nil {
 			return fmt.Errorf("error forwarding services: %s", err)
 		}
+		stopHealth := make(chan struct{})
+		defer close(stopHealth)
+		go reportHealth(r.UI, health, stopHealth)
 		netConfig.ContainerID = id
 	}
 
 	if err := r.UI.Loading("Image", r.Image.Pull(RunStack)); err != nil {
 		return err
 	}
+	if options.remoteLogs {
+		stop := make(chan struct{})
+		messages, err := remoteApp.StreamLogs(logsApp, stop)
+		if err != nil {
+			return err
+		}
+		printed := make(chan struct{})
+		go func() {
+			printRemoteLogs(r.UI.Logs("remote"), remote.AppName(logsApp), messages)
+			close(printed)
+		}()
+		defer func() {
+			close(stop)
+			<-printed
+		}()
+	}
+	recording, err := r.LogFiles.Record(options.name)
+	if err != nil {
+		return err
+	}
+	defer recording.Close()
 	r.UI.Output("Running %s on port %d...", options.name, options.port)
+	r.UI.Result("running", map[string]interface{}{
+		"name": options.name,
+		"ip":   options.ip,
+		"port": options.port,
+	})
 	_, err = r.Runner.Run(&forge.RunConfig{
 		Droplet:       droplet,
 		Stack:         RunStack,
@@ -156,11 +223,77 @@ func (*Run) options(args []string) (*runOptions, error) {
 		set.StringVar(&options.appDir, "d", "", "")
 		set.StringVar(&options.serviceApp, "s", "", "")
 		set.StringVar(&options.forwardApp, "f", "", "")
+		set.StringVar(&options.target, "target", "", "")
 		set.BoolVar(&options.watch, "w", false, "")
 		set.BoolVar(&options.term, "t", false, "")
+		set.BoolVar(&options.remoteLogs, "remote-logs", false, "")
 	})
 }
 
+// stubServices replaces the URLs of the services listed under stubs in
+// local.yml with the URLs of local stubs. When recording through forwarded
+// services, the forwarded services are also tunneled to the host, so that
+// the stubs can reach them.
+func (r *Run) stubServices(services forge.Services, forwardConfig *remote.ForwardDetails) (done func(), err error) {
+	var dones []func()
+	done = func() {
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i]()
+		}
+	}
+	if len(services) == 0 {
+		return done, nil
+	}
+	options, err := r.Config.LoadOptions()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range options.Stubs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tunneled := false
+	for _, name := range names {
+		stub := options.Stubs[name]
+		path := stub.URL
+		if path == "" {
+			path = "$.url"
+		}
+		ok, err := remote.ReplaceCredential(services, name, path, func(target string) (string, error) {
+			if stub.Mode == "record" && forwardConfig != nil && !tunneled {
+				_, tunnelDone, err := r.Tunneler.Tunnel(forwardConfig, "127.0.0.1")
+				if err != nil {
+					return "", err
+				}
+				dones = append(dones, tunnelDone)
+				tunneled = true
+			}
+			url, stubDone, err := r.Stubber.Stub(name, stub.Mode, target)
+			if err != nil {
+				return "", err
+			}
+			dones = append(dones, stubDone)
+			return url, nil
+		})
+		if err != nil {
+			done()
+			return nil, fmt.Errorf("unable to stub %s: %s", name, err)
+		}
+		if !ok {
+			r.UI.Warn("'%s' service not found, not stubbed", name)
+			continue
+		}
+		mode := stub.Mode
+		if mode == "" {
+			mode = "replay"
+		}
+		r.UI.Output("Stubbing %s (%s).", name, mode)
+	}
+	return done, nil
+}
+
 func freePort() (uint, error) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -191,3 +324,27 @@ func waitForHealthy(health <-chan string) error {
 		}
 	}
 }
+
+// reportHealth reports when forwarded services become unhealthy and when
+// they recover, until the health channel or stop is closed.
+func reportHealth(ui UI, health <-chan string, stop <-chan struct{}) {
+	healthy := true
+	for {
+		select {
+		case status, ok := <-health:
+			if !ok {
+				return
+			}
+			switch {
+			case status == "unhealthy" && healthy:
+				ui.Warn("lost connection to forwarded services, reconnecting")
+				healthy = false
+			case status == "healthy" && !healthy:
+				ui.Output("Reconnected to forwarded services.")
+				healthy = true
+			}
+		case <-stop:
+			return
+		}
+	}
+}
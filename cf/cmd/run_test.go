@@ -1,6 +1,7 @@
 package cmd_test
 
 import (
+	"errors"
 	"io/ioutil"
 	"time"
 
@@ -15,7 +16,10 @@ import (
 
 	. "code.cloudfoundry.org/cflocal/cf/cmd"
 	"code.cloudfoundry.org/cflocal/cf/cmd/mocks"
+	"code.cloudfoundry.org/cflocal/config"
+	"code.cloudfoundry.org/cflocal/fs"
 	sharedmocks "code.cloudfoundry.org/cflocal/mocks"
+	"code.cloudfoundry.org/cflocal/remote"
 )
 
 var _ = Describe("Run", func() {
@@ -26,6 +30,11 @@ var _ = Describe("Run", func() {
 		mockForwarder *mocks.MockForwarder
 		mockRemoteApp *mocks.MockRemoteApp
 		mockImage     *mocks.MockImage
+		mockLogFiles  *mocks.MockLogFiles
+		mockSnapshots *mocks.MockSnapshots
+		mockRedactor  *mocks.MockRedactor
+		mockStubber   *mocks.MockStubber
+		mockTunneler  *mocks.MockTunneler
 		mockFS        *mocks.MockFS
 		mockHelp      *mocks.MockHelp
 		mockConfig    *mocks.MockConfig
@@ -39,6 +48,11 @@ var _ = Describe("Run", func() {
 		mockForwarder = mocks.NewMockForwarder(mockCtrl)
 		mockRemoteApp = mocks.NewMockRemoteApp(mockCtrl)
 		mockImage = mocks.NewMockImage(mockCtrl)
+		mockLogFiles = mocks.NewMockLogFiles(mockCtrl)
+		mockSnapshots = mocks.NewMockSnapshots(mockCtrl)
+		mockRedactor = mocks.NewMockRedactor(mockCtrl)
+		mockStubber = mocks.NewMockStubber(mockCtrl)
+		mockTunneler = mocks.NewMockTunneler(mockCtrl)
 		mockFS = mocks.NewMockFS(mockCtrl)
 		mockHelp = mocks.NewMockHelp(mockCtrl)
 		mockConfig = mocks.NewMockConfig(mockCtrl)
@@ -48,6 +62,11 @@ var _ = Describe("Run", func() {
 			Forwarder: mockForwarder,
 			RemoteApp: mockRemoteApp,
 			Image:     mockImage,
+			LogFiles:  mockLogFiles,
+			Snapshots: mockSnapshots,
+			Redactor:  mockRedactor,
+			Stubber:   mockStubber,
+			Tunneler:  mockTunneler,
 			FS:        mockFS,
 			Help:      mockHelp,
 			Config:    mockConfig,
@@ -81,9 +100,10 @@ var _ = Describe("Run", func() {
 			watchDone := make(chan struct{})
 			health := make(chan string, 3)
 			forwardDone, forwardDoneCalls := sharedmocks.NewMockFunc()
+			recording := sharedmocks.NewMockBuffer("")
 
-			forwardConfig := &forge.ForwardDetails{
-				Host: "some-ssh-host",
+			forwardConfig := &remote.ForwardDetails{
+				ForwardDetails: forge.ForwardDetails{Host: "some-ssh-host"},
 			}
 			localYML := &app.YAML{
 				Applications: []*forge.AppConfig{
@@ -99,17 +119,55 @@ var _ = Describe("Run", func() {
 			mockConfig.EXPECT().Load().Return(localYML, nil)
 			mockFS.EXPECT().ReadFile("./some-app.droplet").Return(droplet, int64(100), nil)
 			mockRemoteApp.EXPECT().Services("some-service-app").Return(services, nil)
-			mockRemoteApp.EXPECT().Forward("some-forward-app", services).Return(forwardedServices, forwardConfig, nil)
+			localOptions := &config.Options{
+				Forwarding: map[string]*config.ForwardRule{
+					"some-service": {
+						Host:     "$.db.host",
+						Forwards: []config.ForwardAddress{{From: "some-host:1000", To: "some-other-host:1000"}},
+					},
+				},
+				ForwardedPorts: map[string]uint{"some-service": 40001},
+				Ignore:         []string{"some-pattern"},
+				RemoteServices: &config.RemoteServices{
+					Allow: []string{"services"},
+					Overrides: map[string]map[string]interface{}{
+						"forwarded-services": {"$.password": "some-local-password"},
+					},
+				},
+			}
+			mockConfig.EXPECT().LoadOptions().Return(localOptions, nil).Times(3)
+			mockRemoteApp.EXPECT().Forward("some-forward-app", services, gomock.Any()).Return(forwardedServices, forwardConfig, nil).Do(
+				func(_ string, _ forge.Services, options *remote.ForwardOptions) {
+					Expect(options.Rules).To(Equal(map[string]*remote.ForwardRule{
+						"some-service": {
+							Host:     "$.db.host",
+							Forwards: []remote.ForwardAddress{{From: "some-host:1000", To: "some-other-host:1000"}},
+						},
+					}))
+					Expect(options.Ports).To(Equal(map[string]uint{"some-service": 40001}))
+					Expect(options.Available).NotTo(BeNil())
+					options.Ports["some-new-service"] = 40002
+				},
+			)
+			mockConfig.EXPECT().SaveOptions(localOptions).Do(func(options *config.Options) {
+				Expect(options.ForwardedPorts).To(Equal(map[string]uint{
+					"some-service":     40001,
+					"some-new-service": 40002,
+				}))
+			})
+			mockRedactor.EXPECT().Redact("some-local-password")
 
+			ignore := fs.NewIgnore("some-abs-dir", "some-pattern")
 			gomock.InOrder(
-				mockFS.EXPECT().Watch("some-abs-dir", time.Second).Return(restart, watchDone, nil),
+				mockFS.EXPECT().Ignore("some-abs-dir", "some-pattern").Return(ignore, nil),
+				mockFS.EXPECT().Watch("some-abs-dir", time.Second, ignore).Return(restart, watchDone, nil),
 				mockImage.EXPECT().Pull(NetworkStack).Return(progress),
 				mockForwarder.EXPECT().Forward(gomock.Any()).Return(health, forwardDone, "some-container-id", nil).Do(
 					func(config *forge.ForwardConfig) {
 						Expect(config.AppName).To(Equal("some-app"))
 						Expect(config.Stack).To(Equal(NetworkStack))
 						Expect(config.Color("some-text")).To(Equal(color.GreenString("some-text")))
-						Expect(config.Details).To(Equal(forwardConfig))
+						Expect(config.Details).To(Equal(&forwardConfig.ForwardDetails))
 						Expect(config.ContainerPort).To(Equal("8080"))
 						Expect(config.HostIP).To(Equal("0.0.0.0"))
 						Expect(config.HostPort).To(Equal("3000"))
@@ -117,6 +175,7 @@ var _ = Describe("Run", func() {
 					},
 				),
 				mockImage.EXPECT().Pull(RunStack).Return(progress),
+				mockLogFiles.EXPECT().Record("some-app").Return(recording, nil),
 				mockRunner.EXPECT().Run(gomock.Any()).Return(int64(0), nil).Do(
 					func(config *forge.RunConfig) {
 						Expect(ioutil.ReadAll(config.Droplet)).To(Equal([]byte("some-droplet")))
@@ -143,6 +202,7 @@ var _ = Describe("Run", func() {
 			health <- "starting"
 			health <- "starting"
 			health <- "healthy"
+			mockRedactor.EXPECT().Flush()
 			Expect(cmd.Run([]string{
 				"run", "some-app",
 				"-i", "0.0.0.0",
@@ -153,12 +213,174 @@ var _ = Describe("Run", func() {
 			})).To(Succeed())
 			Expect(forwardDoneCalls()).To(Equal(1))
 			Expect(droplet.Result()).To(BeEmpty())
+			Expect(recording.Result()).To(BeEmpty())
 			Expect(watchDone).To(BeClosed())
+			Expect(forwardedServices["some"][0].Credentials).To(Equal(map[string]interface{}{"password": "some-local-password"}))
 			Expect(mockUI.Out).To(gbytes.Say("Running some-app on port 3000..."))
 			Expect(mockUI.Progress).To(Receive(Equal(mockProgress{Value: "some-progress-forward"})))
 			Expect(mockUI.Progress).To(Receive(Equal(mockProgress{Value: "some-progress-run"})))
 		})
 
+		Context("when --remote-logs is specified", func() {
+			It("should stream the remote app's logs while the droplet runs", func() {
+				messages := make(chan remote.LogMessage, 1)
+				messages <- remote.LogMessage{SourceType: "APP/PROC/WEB", InstanceID: "0", Message: "some-message"}
+				close(messages)
+				progress := make(chan engine.Progress)
+				close(progress)
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockRemoteApp.EXPECT().Services("some-service-app").Return(forge.Services{}, nil)
+				mockConfig.EXPECT().LoadOptions().Return(&config.Options{}, nil)
+				mockRedactor.EXPECT().Redact()
+				gomock.InOrder(
+					mockImage.EXPECT().Pull(RunStack).Return(progress),
+					mockRemoteApp.EXPECT().StreamLogs("some-service-app", gomock.Any()).Return(messages, nil),
+					mockLogFiles.EXPECT().Record("some-app").Return(sharedmocks.NewMockBuffer(""), nil),
+					mockRunner.EXPECT().Run(gomock.Any()).Return(int64(0), nil),
+				)
+				mockRedactor.EXPECT().Flush()
+				Expect(cmd.Run([]string{"run", "some-app", "-s", "some-service-app", "--remote-logs"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say(`some-service-app/APP/PROC/WEB/0.* some-message`))
+			})
+
+			It("should return an error when there is no remote app", func() {
+				Expect(cmd.Run([]string{"run", "some-app", "--remote-logs"})).To(MatchError("--remote-logs requires -s <app> or -f"))
+			})
+		})
+
+		Context("when -s refers to a services snapshot", func() {
+			It("should run the droplet with the services in the snapshot", func() {
+				services := forge.Services{"some-type": {{
+					Name:        "some-service",
+					Credentials: map[string]interface{}{"password": "some-password"},
+				}}}
+				progress := make(chan engine.Progress)
+				close(progress)
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockConfig.EXPECT().LoadOptions().Return(&config.Options{}, nil).Times(2)
+				mockSnapshots.EXPECT().Load("some-snapshot").Return(services, nil)
+				mockRedactor.EXPECT().Redact("some-password")
+				gomock.InOrder(
+					mockImage.EXPECT().Pull(RunStack).Return(progress),
+					mockLogFiles.EXPECT().Record("some-app").Return(sharedmocks.NewMockBuffer(""), nil),
+					mockRunner.EXPECT().Run(gomock.Any()).Return(int64(0), nil).Do(
+						func(config *forge.RunConfig) {
+							Expect(config.AppConfig.Services).To(Equal(services))
+						},
+					),
+				)
+				mockRedactor.EXPECT().Flush()
+				Expect(cmd.Run([]string{"run", "some-app", "-s", "@some-snapshot"})).To(Succeed())
+			})
+
+			It("should return an error when -f refers to a services snapshot", func() {
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockRedactor.EXPECT().Flush()
+				Expect(cmd.Run([]string{"run", "some-app", "-f", "@some-snapshot"})).To(MatchError("services snapshots may only be used with -s"))
+			})
+		})
+
+		Context("when stubs are specified in local.yml", func() {
+			It("should run the droplet with the stubbed service URLs", func() {
+				progress := make(chan engine.Progress)
+				close(progress)
+				localYML := &app.YAML{
+					Applications: []*forge.AppConfig{{
+						Name: "some-app",
+						Services: forge.Services{"some-type": {
+							{Name: "some-api", Credentials: map[string]interface{}{"url": "https://some-host/some-path"}},
+							{Name: "some-other-api", Credentials: map[string]interface{}{"api": map[string]interface{}{"uri": "https://some-other-host"}}},
+						}},
+					}},
+				}
+				stubDone, stubDoneCalls := sharedmocks.NewMockFunc()
+				otherStubDone, otherStubDoneCalls := sharedmocks.NewMockFunc()
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockConfig.EXPECT().LoadOptions().Return(&config.Options{
+					Stubs: map[string]*config.Stub{
+						"some-api":       {},
+						"some-other-api": {Mode: "record", URL: "$.api.uri"},
+						"some-missing":   {},
+					},
+				}, nil)
+				mockStubber.EXPECT().Stub("some-api", "", "https://some-host/some-path").Return("http://some-stub-host:1000/some-path", stubDone, nil)
+				mockStubber.EXPECT().Stub("some-other-api", "record", "https://some-other-host").Return("http://some-stub-host:2000", otherStubDone, nil)
+				gomock.InOrder(
+					mockImage.EXPECT().Pull(RunStack).Return(progress),
+					mockLogFiles.EXPECT().Record("some-app").Return(sharedmocks.NewMockBuffer(""), nil),
+					mockRunner.EXPECT().Run(gomock.Any()).Return(int64(0), nil).Do(
+						func(config *forge.RunConfig) {
+							Expect(config.AppConfig.Services).To(Equal(forge.Services{"some-type": {
+								{Name: "some-api", Credentials: map[string]interface{}{"url": "http://some-stub-host:1000/some-path"}},
+								{Name: "some-other-api", Credentials: map[string]interface{}{"api": map[string]interface{}{"uri": "http://some-stub-host:2000"}}},
+							}}))
+							Expect(stubDoneCalls()).To(Equal(0))
+						},
+					),
+				)
+				mockRedactor.EXPECT().Flush()
+				Expect(cmd.Run([]string{"run", "some-app"})).To(Succeed())
+				Expect(stubDoneCalls()).To(Equal(1))
+				Expect(otherStubDoneCalls()).To(Equal(1))
+				Expect(mockUI.Out).To(gbytes.Say(`Stubbing some-api \(replay\).`))
+				Expect(mockUI.Out).To(gbytes.Say("Warning: 'some-missing' service not found, not stubbed"))
+				Expect(mockUI.Out).To(gbytes.Say(`Stubbing some-other-api \(record\).`))
+			})
+
+			It("should tunnel forwarded services to the host when recording", func() {
+				progress := make(chan engine.Progress)
+				close(progress)
+				services := forge.Services{"some-type": {{Name: "some-api", Credentials: map[string]interface{}{"url": "http://some-host"}}}}
+				forwardedServices := forge.Services{"some-type": {{Name: "some-api", Credentials: map[string]interface{}{"url": "http://localhost:40000"}}}}
+				forwardConfig := &remote.ForwardDetails{ForwardDetails: forge.ForwardDetails{Host: "some-ssh-host"}}
+				health := make(chan string, 1)
+				health <- "healthy"
+				forwardDone, _ := sharedmocks.NewMockFunc()
+				tunnelDone, tunnelDoneCalls := sharedmocks.NewMockFunc()
+				stubDone, _ := sharedmocks.NewMockFunc()
+				localOptions := &config.Options{Stubs: map[string]*config.Stub{"some-api": {Mode: "record"}}}
+				mockConfig.EXPECT().Load().Return(&app.YAML{}, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockRemoteApp.EXPECT().Services("some-forward-app").Return(services, nil)
+				mockConfig.EXPECT().LoadOptions().Return(localOptions, nil).Times(2)
+				mockRemoteApp.EXPECT().Forward("some-forward-app", services, gomock.Any()).Return(forwardedServices, forwardConfig, nil)
+				mockRedactor.EXPECT().Redact()
+				gomock.InOrder(
+					mockTunneler.EXPECT().Tunnel(forwardConfig, "127.0.0.1").Return(make(<-chan string), tunnelDone, nil),
+					mockStubber.EXPECT().Stub("some-api", "record", "http://localhost:40000").Return("http://some-stub-host:1000", stubDone, nil),
+					mockImage.EXPECT().Pull(NetworkStack).Return(progress),
+					mockForwarder.EXPECT().Forward(gomock.Any()).Return(health, forwardDone, "some-container-id", nil),
+					mockImage.EXPECT().Pull(RunStack).Return(progress),
+					mockLogFiles.EXPECT().Record("some-app").Return(sharedmocks.NewMockBuffer(""), nil),
+					mockRunner.EXPECT().Run(gomock.Any()).Return(int64(0), nil),
+				)
+				mockRedactor.EXPECT().Flush()
+				Expect(cmd.Run([]string{"run", "some-app", "-f", "some-forward-app"})).To(Succeed())
+				Expect(tunnelDoneCalls()).To(Equal(1))
+			})
+
+			It("should return an error when a stub cannot be started", func() {
+				localYML := &app.YAML{
+					Applications: []*forge.AppConfig{{
+						Name:     "some-app",
+						Services: forge.Services{"some-type": {{Name: "some-api", Credentials: map[string]interface{}{"url": "https://some-host"}}}},
+					}},
+				}
+				mockConfig.EXPECT().Load().Return(localYML, nil)
+				mockFS.EXPECT().ReadFile("./some-app.droplet").Return(sharedmocks.NewMockBuffer("some-droplet"), int64(12), nil)
+				mockConfig.EXPECT().LoadOptions().Return(&config.Options{
+					Stubs: map[string]*config.Stub{"some-api": {Mode: "some-mode"}},
+				}, nil)
+				mockStubber.EXPECT().Stub("some-api", "some-mode", "https://some-host").Return("", nil, errors.New("some error"))
+				mockRedactor.EXPECT().Flush()
+				Expect(cmd.Run([]string{"run", "some-app"})).To(MatchError("unable to stub some-api: some error"))
+			})
+		})
+
 		// TODO: test app dir when app dir is unspecified (currently tested by integration)
 		// TODO: test without watching
 		// TODO: test -w without -d
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"code.cloudfoundry.org/cflocal/snapshot"
+)
+
+const defaultSnapshotExpiry = 7 * 24 * time.Hour
+
+type Services struct {
+	UI        UI
+	RemoteApp RemoteApp
+	Targets   Targets
+	Snapshots Snapshots
+	Help      Help
+	Config    Config
+}
+
+type servicesPullOptions struct {
+	app     string
+	name    string
+	target  string
+	expires time.Duration
+}
+
+func (s *Services) Match(args []string) bool {
+	return len(args) > 0 && args[0] == "services"
+}
+
+func (s *Services) Run(args []string) error {
+	if len(args) > 1 {
+		switch args[1] {
+		case "pull":
+			return s.pull(args[1:])
+		case "ls":
+			if len(args) > 2 {
+				s.Help.Short()
+				return errors.New("invalid arguments")
+			}
+			return s.list()
+		}
+	}
+	s.Help.Short()
+	return errors.New("services subcommand required: pull or ls")
+}
+
+func (s *Services) pull(args []string) error {
+	options, err := s.pullOptions(args)
+	if err != nil {
+		s.Help.Short()
+		return err
+	}
+	remoteApp, err := selectRemoteApp(s.RemoteApp, s.Targets, options.target)
+	if err != nil {
+		return err
+	}
+	if options.name == "" {
+		if options.name, err = remoteApp.Name(options.app); err != nil {
+			return err
+		}
+	}
+	localOptions, err := s.Config.LoadOptions()
+	if err != nil {
+		return err
+	}
+	services, err := remoteApp.Services(options.app)
+	if err != nil {
+		return err
+	}
+	scope, _ := serviceScope(localOptions)
+	services = scope.Filter(services)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	info := snapshot.Info{
+		Name:    options.name,
+		App:     options.app,
+		Created: now,
+		Expires: now.Add(options.expires),
+	}
+	if err := s.Snapshots.Save(info, services); err != nil {
+		return err
+	}
+	s.UI.Output("Saved services of %s to snapshot @%s (expires %s).", options.app, info.Name, info.Expires.Format(time.RFC3339))
+	s.UI.Result("snapshot", map[string]interface{}{
+		"name":    info.Name,
+		"app":     info.App,
+		"expires": info.Expires.Format(time.RFC3339),
+	})
+	return nil
+}
+
+func (s *Services) list() error {
+	infos, err := s.Snapshots.List()
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		s.UI.Output("No services snapshots found.")
+		return nil
+	}
+	table := &bytes.Buffer{}
+	w := tabwriter.NewWriter(table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "snapshot\tapp\tcreated\texpires")
+	for _, info := range infos {
+		expires := info.Expires.Format(time.RFC3339)
+		if info.Expired() {
+			expires += " (expired)"
+		}
+		fmt.Fprintf(w, "@%s\t%s\t%s\t%s\n", info.Name, info.App, info.Created.Format(time.RFC3339), expires)
+		s.UI.Result("snapshot", map[string]interface{}{
+			"name":    info.Name,
+			"app":     info.App,
+			"created": info.Created.Format(time.RFC3339),
+			"expires": info.Expires.Format(time.RFC3339),
+			"expired": info.Expired(),
+		})
+	}
+	w.Flush()
+	s.UI.Output("%s", strings.TrimSuffix(table.String(), "\n"))
+	return nil
+}
+
+func (*Services) pullOptions(args []string) (*servicesPullOptions, error) {
+	options := &servicesPullOptions{}
+
+	err := parseOptions(args, func(name string, set *flag.FlagSet) {
+		options.app = name
+		set.StringVar(&options.name, "n", "", "")
+		set.StringVar(&options.target, "target", "", "")
+		set.DurationVar(&options.expires, "expires", defaultSnapshotExpiry, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if options.expires <= 0 {
+		return nil, fmt.Errorf("invalid expiry: %s", options.expires)
+	}
+	return options, nil
+}
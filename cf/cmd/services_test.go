@@ -0,0 +1,166 @@
+package cmd_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/buildpack/forge"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	. "code.cloudfoundry.org/cflocal/cf/cmd"
+	"code.cloudfoundry.org/cflocal/cf/cmd/mocks"
+	"code.cloudfoundry.org/cflocal/config"
+	sharedmocks "code.cloudfoundry.org/cflocal/mocks"
+	"code.cloudfoundry.org/cflocal/snapshot"
+)
+
+var _ = Describe("Services", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockUI        *sharedmocks.MockUI
+		mockRemoteApp *mocks.MockRemoteApp
+		mockTargets   *mocks.MockTargets
+		mockSnapshots *mocks.MockSnapshots
+		mockHelp      *mocks.MockHelp
+		mockConfig    *mocks.MockConfig
+		cmd           *Services
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockUI = sharedmocks.NewMockUI()
+		mockRemoteApp = mocks.NewMockRemoteApp(mockCtrl)
+		mockTargets = mocks.NewMockTargets(mockCtrl)
+		mockSnapshots = mocks.NewMockSnapshots(mockCtrl)
+		mockHelp = mocks.NewMockHelp(mockCtrl)
+		mockConfig = mocks.NewMockConfig(mockCtrl)
+		cmd = &Services{
+			UI:        mockUI,
+			RemoteApp: mockRemoteApp,
+			Targets:   mockTargets,
+			Snapshots: mockSnapshots,
+			Help:      mockHelp,
+			Config:    mockConfig,
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("#Match", func() {
+		It("should return true when the first argument is services", func() {
+			Expect(cmd.Match([]string{"services"})).To(BeTrue())
+			Expect(cmd.Match([]string{"not-services"})).To(BeFalse())
+			Expect(cmd.Match([]string{})).To(BeFalse())
+			Expect(cmd.Match(nil)).To(BeFalse())
+		})
+	})
+
+	Describe("#Run", func() {
+		Context("with pull", func() {
+			It("should save the app's allowed services to a snapshot", func() {
+				services := forge.Services{"some-type": {
+					{Name: "some-service"},
+					{Name: "some-other-service"},
+				}}
+				localOptions := &config.Options{
+					RemoteServices: &config.RemoteServices{Allow: []string{"some-service"}},
+				}
+				mockConfig.EXPECT().LoadOptions().Return(localOptions, nil)
+				mockRemoteApp.EXPECT().Services("some-app").Return(services, nil)
+				var info snapshot.Info
+				mockSnapshots.EXPECT().Save(gomock.Any(), forge.Services{"some-type": {{Name: "some-service"}}}).Do(
+					func(i snapshot.Info, _ forge.Services) { info = i },
+				)
+
+				Expect(cmd.Run([]string{"services", "pull", "some-app", "-n", "some-snapshot", "-expires", "1h"})).To(Succeed())
+				Expect(info.Name).To(Equal("some-snapshot"))
+				Expect(info.App).To(Equal("some-app"))
+				Expect(info.Created).To(BeTemporally("~", time.Now(), 5*time.Second))
+				Expect(info.Expires.Sub(info.Created)).To(Equal(time.Hour))
+				Expect(mockUI.Out).To(gbytes.Say("Saved services of some-app to snapshot @some-snapshot"))
+				Expect(mockUI.Results["snapshot"]).To(Equal(map[string]interface{}{
+					"name":    "some-snapshot",
+					"app":     "some-app",
+					"expires": info.Expires.Format(time.RFC3339),
+				}))
+			})
+
+			It("should name the snapshot after the app by default", func() {
+				mockTargetApp := mocks.NewMockRemoteApp(mockCtrl)
+				mockTargets.EXPECT().RemoteApp("some-target").Return(mockTargetApp, nil)
+				mockTargetApp.EXPECT().Name("some-app").Return("some-app", nil)
+				mockConfig.EXPECT().LoadOptions().Return(&config.Options{}, nil)
+				mockTargetApp.EXPECT().Services("some-app").Return(forge.Services{}, nil)
+				mockSnapshots.EXPECT().Save(gomock.Any(), forge.Services{}).Do(
+					func(info snapshot.Info, _ forge.Services) {
+						Expect(info.Name).To(Equal("some-app"))
+						Expect(info.Expires.Sub(info.Created)).To(Equal(7 * 24 * time.Hour))
+					},
+				)
+
+				Expect(cmd.Run([]string{"services", "pull", "some-app", "--target", "some-target"})).To(Succeed())
+			})
+
+			It("should name the snapshot after the app when the app is referenced by GUID", func() {
+				guid := "8b0e3a5c-1d2f-4e6a-9b7c-0d1e2f3a4b5c"
+				mockRemoteApp.EXPECT().Name(guid).Return("some-app", nil)
+				mockConfig.EXPECT().LoadOptions().Return(&config.Options{}, nil)
+				mockRemoteApp.EXPECT().Services(guid).Return(forge.Services{}, nil)
+				mockSnapshots.EXPECT().Save(gomock.Any(), forge.Services{}).Do(
+					func(info snapshot.Info, _ forge.Services) {
+						Expect(info.Name).To(Equal("some-app"))
+					},
+				)
+
+				Expect(cmd.Run([]string{"services", "pull", guid})).To(Succeed())
+			})
+
+			It("should return an error when the snapshot cannot be saved", func() {
+				mockRemoteApp.EXPECT().Name("some-app").Return("some-app", nil)
+				mockConfig.EXPECT().LoadOptions().Return(&config.Options{}, nil)
+				mockRemoteApp.EXPECT().Services("some-app").Return(forge.Services{}, nil)
+				mockSnapshots.EXPECT().Save(gomock.Any(), forge.Services{}).Return(errors.New("some error"))
+
+				Expect(cmd.Run([]string{"services", "pull", "some-app"})).To(MatchError("some error"))
+			})
+
+			It("should return an error when the expiry is invalid", func() {
+				mockHelp.EXPECT().Short()
+				Expect(cmd.Run([]string{"services", "pull", "some-app", "-expires", "-1h"})).To(MatchError("invalid expiry: -1h0m0s"))
+			})
+		})
+
+		Context("with ls", func() {
+			It("should list the snapshots", func() {
+				created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+				mockSnapshots.EXPECT().List().Return([]snapshot.Info{
+					{Name: "some-snapshot", App: "some-app", Created: created, Expires: created.Add(time.Hour)},
+					{Name: "some-other-snapshot", App: "some-other-app", Created: created, Expires: time.Now().Add(time.Hour)},
+				}, nil)
+
+				Expect(cmd.Run([]string{"services", "ls"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say(`snapshot\s+app\s+created\s+expires`))
+				Expect(mockUI.Out).To(gbytes.Say(`@some-snapshot\s+some-app\s+2018-01-02T03:04:05Z\s+2018-01-02T04:04:05Z \(expired\)`))
+				Expect(mockUI.Out).To(gbytes.Say(`@some-other-snapshot\s+some-other-app\s+2018-01-02T03:04:05Z\s+\S+\n`))
+			})
+
+			It("should show when there are no snapshots", func() {
+				mockSnapshots.EXPECT().List().Return(nil, nil)
+
+				Expect(cmd.Run([]string{"services", "ls"})).To(Succeed())
+				Expect(mockUI.Out).To(gbytes.Say("No services snapshots found."))
+			})
+		})
+
+		It("should return an error when the subcommand is missing or invalid", func() {
+			mockHelp.EXPECT().Short().Times(2)
+			Expect(cmd.Run([]string{"services"})).To(MatchError("services subcommand required: pull or ls"))
+			Expect(cmd.Run([]string{"services", "some-subcommand"})).To(MatchError("services subcommand required: pull or ls"))
+		})
+	})
+})
@@ -15,7 +15,11 @@ type Stage struct {
 	UI        UI
 	Stager    Stager
 	RemoteApp RemoteApp
+	Targets   Targets
 	Image     Image
+	LogFiles  LogFiles
+	Snapshots Snapshots
+	Redactor  Redactor
 	TarApp    func(string, ...string) (io.ReadCloser, error)
 	FS        FS
 	Help      Help
@@ -28,6 +32,7 @@ type stageOptions struct {
 	app         string
 	serviceApp  string
 	forwardApp  string
+	target      string
 	forceDetect bool
 }
 
@@ -50,7 +55,20 @@ func (s *Stage) Run(args []string) error {
 		return err
 	}
 
-	appTar, err := s.TarApp(options.app, `^.+\.droplet$`, `^\..+\.cache$`)
+	localOptions, err := s.Config.LoadOptions()
+	if err != nil {
+		return err
+	}
+	ignore, err := s.FS.Ignore(options.app, localOptions.Ignore...)
+	if err != nil {
+		return err
+	}
+	excludes, err := ignore.Excludes()
+	if err != nil {
+		return err
+	}
+	excludes = append([]string{`^.+\.droplet$`, `^\..+\.cache$`, `^\.cflocal(/.*)?$`}, excludes...)
+	appTar, err := s.TarApp(options.app, excludes...)
 	if err != nil {
 		return err
 	}
@@ -78,7 +96,13 @@ func (s *Stage) Run(args []string) error {
 		buildpackZips[checksum] = buildpackZip
 	}
 
-	remoteServices, _, err := getRemoteServices(s.RemoteApp, options.serviceApp, options.forwardApp)
+	remoteApp, err := selectRemoteApp(s.RemoteApp, s.Targets, options.target)
+	if err != nil {
+		return err
+	}
+	// deferred first, so that output is flushed after all containers stop
+	defer s.Redactor.Flush()
+	remoteServices, _, err := getRemoteServices(remoteApp, s.Config, s.Snapshots, s.Redactor, options.serviceApp, options.forwardApp)
 	if err != nil {
 		return err
 	}
@@ -98,6 +122,11 @@ func (s *Stage) Run(args []string) error {
 	if err := s.UI.Loading("Image", s.Image.Pull(BuildStack)); err != nil {
 		return err
 	}
+	recording, err := s.LogFiles.Record(options.name)
+	if err != nil {
+		return err
+	}
+	defer recording.Close()
 	droplet, err := s.Stager.Stage(&forge.StageConfig{
 		AppTar:        appTar,
 		Cache:         cache,
@@ -117,11 +146,32 @@ func (s *Stage) Run(args []string) error {
 	if err := s.streamOut(droplet, dropletPath); err != nil {
 		return err
 	}
+	if err := s.clearStack(options.name); err != nil {
+		return err
+	}
 
 	s.UI.Output("Successfully staged: %s", options.name)
+	s.UI.Result("staged", map[string]interface{}{
+		"name":    options.name,
+		"droplet": dropletPath,
+	})
 	return nil
 }
 
+// clearStack removes the stack recorded for a pulled droplet, since the
+// droplet is replaced by one that is built for BuildStack.
+func (s *Stage) clearStack(name string) error {
+	localOptions, err := s.Config.LoadOptions()
+	if err != nil {
+		return err
+	}
+	if _, ok := localOptions.DropletStacks[name]; !ok {
+		return nil
+	}
+	delete(localOptions.DropletStacks, name)
+	return s.Config.SaveOptions(localOptions)
+}
+
 func (s *Stage) streamOut(stream engine.Stream, path string) error {
 	file, err := s.FS.WriteFile(path)
 	if err != nil {
@@ -140,6 +190,7 @@ func (*Stage) options(args []string) (*stageOptions, error) {
 		set.Var(&options.buildpacks, "b", "")
 		set.StringVar(&options.serviceApp, "s", "", "")
 		set.StringVar(&options.forwardApp, "f", "", "")
+		set.StringVar(&options.target, "target", "", "")
 		set.BoolVar(&options.forceDetect, "e", false, "")
 	})
 }
@@ -16,7 +16,10 @@ import (
 
 	. "code.cloudfoundry.org/cflocal/cf/cmd"
 	"code.cloudfoundry.org/cflocal/cf/cmd/mocks"
+	"code.cloudfoundry.org/cflocal/config"
+	"code.cloudfoundry.org/cflocal/fs"
 	sharedmocks "code.cloudfoundry.org/cflocal/mocks"
+	"code.cloudfoundry.org/cflocal/remote"
 )
 
 var _ = Describe("Stage", func() {
@@ -27,6 +30,9 @@ var _ = Describe("Stage", func() {
 		mockRemoteApp *mocks.MockRemoteApp
 		mockLocalApp  *mocks.MockLocalApp
 		mockImage     *mocks.MockImage
+		mockLogFiles  *mocks.MockLogFiles
+		mockSnapshots *mocks.MockSnapshots
+		mockRedactor  *mocks.MockRedactor
 		mockFS        *mocks.MockFS
 		mockHelp      *mocks.MockHelp
 		mockConfig    *mocks.MockConfig
@@ -40,6 +46,9 @@ var _ = Describe("Stage", func() {
 		mockRemoteApp = mocks.NewMockRemoteApp(mockCtrl)
 		mockLocalApp = mocks.NewMockLocalApp(mockCtrl)
 		mockImage = mocks.NewMockImage(mockCtrl)
+		mockLogFiles = mocks.NewMockLogFiles(mockCtrl)
+		mockSnapshots = mocks.NewMockSnapshots(mockCtrl)
+		mockRedactor = mocks.NewMockRedactor(mockCtrl)
 		mockFS = mocks.NewMockFS(mockCtrl)
 		mockHelp = mocks.NewMockHelp(mockCtrl)
 		mockConfig = mocks.NewMockConfig(mockCtrl)
@@ -48,6 +57,9 @@ var _ = Describe("Stage", func() {
 			Stager:    mockStager,
 			RemoteApp: mockRemoteApp,
 			Image:     mockImage,
+			LogFiles:  mockLogFiles,
+			Snapshots: mockSnapshots,
+			Redactor:  mockRedactor,
 			TarApp:    mockLocalApp.Tar,
 			FS:        mockFS,
 			Help:      mockHelp,
@@ -79,11 +91,12 @@ var _ = Describe("Stage", func() {
 			cache := sharedmocks.NewMockBuffer("some-old-cache")
 			droplet := sharedmocks.NewMockBuffer("some-droplet")
 			dropletFile := sharedmocks.NewMockBuffer("")
+			recording := sharedmocks.NewMockBuffer("")
 
 			services := forge.Services{"some": {{Name: "services"}}}
 			forwardedServices := forge.Services{"some": {{Name: "forwarded-services"}}}
-			forwardConfig := &forge.ForwardDetails{
-				Host: "some-ssh-host",
+			forwardConfig := &remote.ForwardDetails{
+				ForwardDetails: forge.ForwardDetails{Host: "some-ssh-host"},
 			}
 
 			localYML := &app.YAML{
@@ -105,14 +118,27 @@ var _ = Describe("Stage", func() {
 			}
 
 			mockConfig.EXPECT().Load().Return(localYML, nil)
-			mockLocalApp.EXPECT().Tar("some-app-dir", `^.+\.droplet$`, `^\..+\.cache$`).Return(appTar, nil)
+			localOptions := &config.Options{
+				Ignore:        []string{"node_modules"},
+				DropletStacks: map[string]string{"some-app": "some-stack", "some-other-app": "some-stack"},
+			}
+			mockConfig.EXPECT().LoadOptions().Return(localOptions, nil).Times(3)
+			mockFS.EXPECT().Ignore("some-app-dir", "node_modules").Return(fs.NewIgnore("", "node_modules"), nil)
+			mockLocalApp.EXPECT().Tar("some-app-dir",
+				`^.+\.droplet$`, `^\..+\.cache$`, `^\.cflocal(/.*)?$`,
+				`^(?:.*/)?\.cfignore(?:/.*)?$`, `^manifest\.yml(?:/.*)?$`, `^(?:.*/)?\.gitignore(?:/.*)?$`,
+				`^(?:.*/)?\.git(?:/.*)?$`, `^(?:.*/)?\.hg(?:/.*)?$`, `^(?:.*/)?\.svn(?:/.*)?$`,
+				`^(?:.*/)?_darcs(?:/.*)?$`, `^(?:.*/)?\.DS_Store(?:/.*)?$`, `^(?:.*/)?node_modules(?:/.*)?$`,
+			).Return(appTar, nil)
 			mockFS.EXPECT().ReadFile("some-buildpack-one").Return(buildpackZip1, int64(20), nil)
 			mockFS.EXPECT().ReadFile("some-buildpack-two").Return(buildpackZip2, int64(21), nil)
 			mockRemoteApp.EXPECT().Services("some-service-app").Return(services, nil)
-			mockRemoteApp.EXPECT().Forward("some-forward-app", services).Return(forwardedServices, forwardConfig, nil)
+			mockRemoteApp.EXPECT().Forward("some-forward-app", services, gomock.Any()).Return(forwardedServices, forwardConfig, nil)
+			mockRedactor.EXPECT().Redact()
 			mockFS.EXPECT().OpenFile("./.some-app.cache").Return(cache, int64(100), nil)
 			gomock.InOrder(
 				mockImage.EXPECT().Pull(BuildStack).Return(progress),
+				mockLogFiles.EXPECT().Record("some-app").Return(recording, nil),
 				mockStager.EXPECT().Stage(gomock.Any()).Do(
 					func(config *forge.StageConfig) {
 						Expect(ioutil.ReadAll(config.AppTar)).To(Equal([]byte("some-app-tar")))
@@ -143,8 +169,13 @@ var _ = Describe("Stage", func() {
 					},
 				).Return(engine.NewStream(droplet, int64(droplet.Len())), nil),
 				mockFS.EXPECT().WriteFile("./some-app.droplet").Return(dropletFile, nil),
+				mockConfig.EXPECT().SaveOptions(&config.Options{
+					Ignore:        []string{"node_modules"},
+					DropletStacks: map[string]string{"some-other-app": "some-stack"},
+				}),
 			)
 
+			mockRedactor.EXPECT().Flush()
 			Expect(cmd.Run([]string{
 				"stage", "some-app", "-e",
 				"-b", "some-buildpack-one",
@@ -159,8 +190,13 @@ var _ = Describe("Stage", func() {
 			Expect(cache.Result()).To(Equal("some-new-cache"))
 			Expect(droplet.Result()).To(BeEmpty())
 			Expect(dropletFile.Result()).To(Equal("some-droplet"))
+			Expect(recording.Result()).To(BeEmpty())
 			Expect(mockUI.Out).To(gbytes.Say("Warning: 'some-forward-app' app selected for service forwarding will not be used"))
 			Expect(mockUI.Out).To(gbytes.Say("Successfully staged: some-app"))
+			Expect(mockUI.Results["staged"]).To(Equal(map[string]interface{}{
+				"name":    "some-app",
+				"droplet": "./some-app.droplet",
+			}))
 			Expect(mockUI.Progress).To(Receive(Equal(mockProgress{Value: "some-progress"})))
 		})
 
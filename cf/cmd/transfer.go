@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/buildpack/forge/engine"
+)
+
+const (
+	transferRetries        = 3
+	transferUpdateInterval = 200 * time.Millisecond
+	transferBarWidth       = 20
+)
+
+type transferProgress struct {
+	done, total int64
+}
+
+func (t transferProgress) Status() (string, error) {
+	if t.total <= 0 {
+		return formatBytes(t.done), nil
+	}
+	filled := int(t.done * transferBarWidth / t.total)
+	if filled > transferBarWidth {
+		filled = transferBarWidth
+	}
+	return fmt.Sprintf("[%s%s] %s / %s",
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", transferBarWidth-filled),
+		formatBytes(t.done), formatBytes(t.total),
+	), nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for i := n / unit; i >= unit; i /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+type countingWriter struct {
+	io.Writer
+	count *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.Writer.Write(p)
+	atomic.AddInt64(c.count, int64(n))
+	return n, err
+}
+
+type countingReader struct {
+	io.Reader
+	count *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	atomic.AddInt64(c.count, int64(n))
+	return n, err
+}
+
+// withProgress shows a progress bar while transfer runs. The transfer must
+// add the number of bytes it moves to count, which starts at offset.
+func withProgress(ui UI, message string, offset, total int64, transfer func(count *int64) error) error {
+	count := offset
+	progress := make(chan engine.Progress)
+	loaded := make(chan struct{})
+	go func() {
+		ui.Loading(message, progress)
+		close(loaded)
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- transfer(&count)
+	}()
+
+	ticker := time.NewTicker(transferUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			progress <- transferProgress{atomic.LoadInt64(&count), total}
+		case err := <-done:
+			progress <- transferProgress{atomic.LoadInt64(&count), total}
+			close(progress)
+			<-loaded
+			return err
+		}
+	}
+}
+
+func isTransient(err error) bool {
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"text/tabwriter"
+)
+
+type Tunnel struct {
+	UI        UI
+	Tunneler  Tunneler
+	RemoteApp RemoteApp
+	Targets   Targets
+	Help      Help
+	Config    Config
+	Exit      <-chan struct{}
+}
+
+type tunnelOptions struct {
+	name   string
+	ip     string
+	target string
+}
+
+func (t *Tunnel) Match(args []string) bool {
+	return len(args) > 0 && args[0] == "tunnel"
+}
+
+func (t *Tunnel) Run(args []string) error {
+	options, err := t.options(args)
+	if err != nil {
+		t.Help.Short()
+		return err
+	}
+	remoteApp, err := selectRemoteApp(t.RemoteApp, t.Targets, options.target)
+	if err != nil {
+		return err
+	}
+	_, details, err := getRemoteServices(remoteApp, t.Config, nil, nil, "", options.name)
+	if err != nil {
+		return err
+	}
+	if details == nil {
+		return fmt.Errorf("no services to forward for %s", options.name)
+	}
+	health, done, err := t.Tunneler.Tunnel(details, options.ip)
+	if err != nil {
+		return err
+	}
+	defer done()
+	stopHealth := make(chan struct{})
+	defer close(stopHealth)
+	go reportHealth(t.UI, health, stopHealth)
+
+	addresses := map[string]interface{}{}
+	table := &bytes.Buffer{}
+	w := tabwriter.NewWriter(table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "service\tlocal address")
+	for _, forward := range details.Forwards {
+		address := net.JoinHostPort(options.ip, forward.From)
+		addresses[forward.Name] = address
+		fmt.Fprintf(w, "%s\t%s\n", forward.Name, address)
+	}
+	w.Flush()
+	t.UI.Output("Tunneling services for %s...", options.name)
+	t.UI.Output("%s", strings.TrimSuffix(table.String(), "\n"))
+	t.UI.Result("tunneling", map[string]interface{}{
+		"name":      options.name,
+		"ip":        options.ip,
+		"addresses": addresses,
+	})
+	t.UI.Output("Press Ctrl-C to stop.")
+	<-t.Exit
+	return nil
+}
+
+func (*Tunnel) options(args []string) (*tunnelOptions, error) {
+	options := &tunnelOptions{}
+
+	return options, parseOptions(args, func(name string, set *flag.FlagSet) {
+		options.name = name
+		set.StringVar(&options.ip, "i", "127.0.0.1", "")
+		set.StringVar(&options.target, "target", "", "")
+	})
+}
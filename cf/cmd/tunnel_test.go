@@ -0,0 +1,121 @@
+package cmd_test
+
+import (
+	"github.com/buildpack/forge"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	. "code.cloudfoundry.org/cflocal/cf/cmd"
+	"code.cloudfoundry.org/cflocal/cf/cmd/mocks"
+	"code.cloudfoundry.org/cflocal/config"
+	sharedmocks "code.cloudfoundry.org/cflocal/mocks"
+	"code.cloudfoundry.org/cflocal/remote"
+)
+
+var _ = Describe("Tunnel", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		mockUI        *sharedmocks.MockUI
+		mockTunneler  *mocks.MockTunneler
+		mockRemoteApp *mocks.MockRemoteApp
+		mockTargets   *mocks.MockTargets
+		mockHelp      *mocks.MockHelp
+		mockConfig    *mocks.MockConfig
+		exit          chan struct{}
+		cmd           *Tunnel
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockUI = sharedmocks.NewMockUI()
+		mockTunneler = mocks.NewMockTunneler(mockCtrl)
+		mockRemoteApp = mocks.NewMockRemoteApp(mockCtrl)
+		mockTargets = mocks.NewMockTargets(mockCtrl)
+		mockHelp = mocks.NewMockHelp(mockCtrl)
+		mockConfig = mocks.NewMockConfig(mockCtrl)
+		exit = make(chan struct{})
+		cmd = &Tunnel{
+			UI:        mockUI,
+			Tunneler:  mockTunneler,
+			RemoteApp: mockRemoteApp,
+			Targets:   mockTargets,
+			Help:      mockHelp,
+			Config:    mockConfig,
+			Exit:      exit,
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("#Match", func() {
+		It("should return true when the first argument is tunnel", func() {
+			Expect(cmd.Match([]string{"tunnel"})).To(BeTrue())
+			Expect(cmd.Match([]string{"not-tunnel"})).To(BeFalse())
+			Expect(cmd.Match([]string{})).To(BeFalse())
+			Expect(cmd.Match(nil)).To(BeFalse())
+		})
+	})
+
+	Describe("#Run", func() {
+		It("should tunnel the app's services to the host until the plugin exits", func() {
+			services := forge.Services{"some-type": {{Name: "some-service"}}}
+			forwardDetails := &remote.ForwardDetails{
+				ForwardDetails: forge.ForwardDetails{
+					Host: "some-ssh-host",
+					Forwards: []forge.Forward{
+						{Name: "some-service:some-type[0]", From: "40000", To: "some-host:1000"},
+						{Name: "some-other-service:some-type[1]", From: "40001", To: "some-other-host:2000"},
+					},
+				},
+			}
+			localOptions := &config.Options{ForwardedPorts: map[string]uint{"some-service": 40000}}
+			mockRemoteApp.EXPECT().Services("some-app").Return(services, nil)
+			mockConfig.EXPECT().LoadOptions().Return(localOptions, nil)
+			mockRemoteApp.EXPECT().Forward("some-app/2", services, gomock.Any()).Return(services, forwardDetails, nil).Do(
+				func(_ string, _ forge.Services, options *remote.ForwardOptions) {
+					Expect(options.Ports).To(Equal(map[string]uint{"some-service": 40000}))
+				},
+			)
+			health := make(chan string)
+			done := false
+			mockTunneler.EXPECT().Tunnel(forwardDetails, "0.0.0.0").Return((<-chan string)(health), func() { done = true }, nil)
+
+			result := make(chan error)
+			go func() { result <- cmd.Run([]string{"tunnel", "some-app/2", "-i", "0.0.0.0"}) }()
+			Eventually(mockUI.Out).Should(gbytes.Say("Tunneling services for some-app/2..."))
+			Expect(mockUI.Out).To(gbytes.Say(`some-service:some-type\[0\]\s+0.0.0.0:40000`))
+			Expect(mockUI.Out).To(gbytes.Say(`some-other-service:some-type\[1\]\s+0.0.0.0:40001`))
+			Consistently(result).ShouldNot(Receive())
+			Expect(done).To(BeFalse())
+
+			health <- "unhealthy"
+			Eventually(mockUI.Out).Should(gbytes.Say("Warning: lost connection to forwarded services, reconnecting"))
+			health <- "healthy"
+			Eventually(mockUI.Out).Should(gbytes.Say("Reconnected to forwarded services."))
+
+			close(exit)
+			Eventually(result).Should(Receive(BeNil()))
+			Expect(done).To(BeTrue())
+			Expect(mockUI.Results["tunneling"]).To(Equal(map[string]interface{}{
+				"name": "some-app/2",
+				"ip":   "0.0.0.0",
+				"addresses": map[string]interface{}{
+					"some-service:some-type[0]":       "0.0.0.0:40000",
+					"some-other-service:some-type[1]": "0.0.0.0:40001",
+				},
+			}))
+		})
+
+		It("should return an error when the app has no services to forward", func() {
+			mockRemoteApp.EXPECT().Services("some-app").Return(forge.Services{}, nil)
+			mockConfig.EXPECT().LoadOptions().Return(&config.Options{}, nil)
+			mockRemoteApp.EXPECT().Forward("some-app", forge.Services{}, gomock.Any()).Return(forge.Services{}, nil, nil)
+
+			Expect(cmd.Run([]string{"tunnel", "some-app"})).To(MatchError("no services to forward for some-app"))
+		})
+	})
+})
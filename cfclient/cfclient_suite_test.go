@@ -0,0 +1,13 @@
+package cfclient_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCFClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CF Client Suite")
+}
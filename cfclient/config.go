@@ -0,0 +1,81 @@
+package cfclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Config struct {
+	Target                string
+	APIVersion            string
+	AuthorizationEndpoint string
+	LoggregatorEndPoint   string
+	DopplerEndPoint       string
+	UaaEndpoint           string
+	AccessToken           string
+	RefreshToken          string
+	UAAOAuthClient        string
+	UAAOAuthClientSecret  string
+	SSHOAuthClient        string
+	OrganizationFields    struct {
+		GUID string
+		Name string
+	}
+	SpaceFields struct {
+		GUID string
+		Name string
+	}
+	SSLDisabled bool
+}
+
+// ConfigPath returns the location of the cf CLI configuration file,
+// respecting CF_HOME in the same way as the cf CLI.
+func ConfigPath() (string, error) {
+	home := os.Getenv("CF_HOME")
+	if home == "" {
+		home = userHome()
+	}
+	if home == "" {
+		return "", errors.New("unable to determine home directory")
+	}
+	return filepath.Join(home, ".cf", "config.json"), nil
+}
+
+// TokenPath returns the location where tokens for a named target are cached,
+// separately from the cf CLI configuration.
+func TokenPath(target string) (string, error) {
+	if target == "" || target == "." || target == ".." || strings.ContainsAny(target, `/\`) {
+		return "", fmt.Errorf("invalid target name '%s'", target)
+	}
+	home := userHome()
+	if home == "" {
+		return "", errors.New("unable to determine home directory")
+	}
+	return filepath.Join(home, ".cflocal", "tokens", target+".json"), nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	config := &Config{}
+	if err := json.NewDecoder(file).Decode(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func userHome() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	return os.Getenv("USERPROFILE")
+}
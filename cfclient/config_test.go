@@ -0,0 +1,37 @@
+package cfclient_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cflocal/cfclient"
+)
+
+var _ = Describe("Config", func() {
+	Describe("TokenPath", func() {
+		var home string
+
+		BeforeEach(func() {
+			home = os.Getenv("HOME")
+			Expect(os.Setenv("HOME", "/some-home")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("HOME", home)).To(Succeed())
+		})
+
+		It("should return a file for the target in the cflocal tokens directory", func() {
+			Expect(TokenPath("some-target")).To(Equal(filepath.Join("/some-home", ".cflocal", "tokens", "some-target.json")))
+		})
+
+		It("should return an error when the target name is not a file name", func() {
+			for _, name := range []string{"", ".", "..", "../some-target", `some\\target`, "some/target"} {
+				_, err := TokenPath(name)
+				Expect(err).To(MatchError("invalid target name '" + name + "'"))
+			}
+		})
+	})
+})
@@ -0,0 +1,532 @@
+package cfclient
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cflocal/cfplugin/models"
+)
+
+var (
+	ErrCLIRequired   = errors.New("this command requires the cf CLI")
+	errNoCredentials = errors.New("no credentials available to renew access token")
+)
+
+// Connection implements cfplugin.CliConnection using the cf CLI configuration
+// file and the Cloud Controller API directly, so that cflocal may be used
+// without the cf CLI.
+type Connection struct {
+	Config      *Config
+	Credentials *Credentials
+	HTTP        *http.Client
+	TokenPath   string
+
+	mutex sync.Mutex
+}
+
+// Credentials allow a Connection to obtain new access tokens without the
+// cf CLI, using either a UAA client or a refresh token.
+type Credentials struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+}
+
+// Info contains the endpoints advertised by the Cloud Controller.
+type Info struct {
+	APIVersion               string `json:"api_version"`
+	AuthorizationEndpoint    string `json:"authorization_endpoint"`
+	TokenEndpoint            string `json:"token_endpoint"`
+	DopplerEndpoint          string `json:"doppler_logging_endpoint"`
+	AppSSHEndpoint           string `json:"app_ssh_endpoint"`
+	AppSSHOAuthClient        string `json:"app_ssh_oauth_client"`
+	AppSSHHostKeyFingerprint string `json:"app_ssh_host_key_fingerprint"`
+}
+
+// tokenCache is the content of a token file. Tokens are only used for the
+// API that they were issued for.
+type tokenCache struct {
+	Target       string
+	AccessToken  string
+	RefreshToken string
+}
+
+const tokenExpiryMargin = 30 * time.Second
+
+func NewConnection(configPath string) (*Connection, error) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return newConnection(config), nil
+}
+
+// NewTargetConnection returns a Connection to the provided API that caches
+// its tokens at tokenPath instead of using the cf CLI configuration. Cached
+// tokens that were issued for a different API are ignored.
+func NewTargetConnection(api string, skipSSLValidation bool, tokenPath string) (*Connection, error) {
+	config := &Config{SSLDisabled: skipSSLValidation}
+	tokenFile, err := os.Open(tokenPath)
+	if err == nil {
+		defer tokenFile.Close()
+		var cache tokenCache
+		if err := json.NewDecoder(tokenFile).Decode(&cache); err != nil {
+			return nil, err
+		}
+		if cache.Target == strings.TrimSuffix(api, "/") {
+			config.AccessToken = cache.AccessToken
+			config.RefreshToken = cache.RefreshToken
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	connection := newConnection(config)
+	connection.TokenPath = tokenPath
+	if err := connection.Connect(api); err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+func newConnection(config *Config) *Connection {
+	return &Connection{
+		Config: config,
+		HTTP: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: config.SSLDisabled,
+				},
+			},
+		},
+	}
+}
+
+// Login obtains an access token for the provided API using the provided
+// credentials. The credentials are retained so that expired tokens may be
+// renewed.
+func (c *Connection) Login(api string, credentials *Credentials) error {
+	if err := c.Connect(api); err != nil {
+		return err
+	}
+	c.Config.AccessToken = ""
+	c.Config.RefreshToken = credentials.RefreshToken
+	c.Credentials = credentials
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.renewToken()
+}
+
+// Connect sets the API and retrieves its endpoints without logging in.
+func (c *Connection) Connect(api string) error {
+	c.Config.Target = strings.TrimSuffix(api, "/")
+	info, err := c.Info()
+	if err != nil {
+		return err
+	}
+	c.Config.APIVersion = info.APIVersion
+	c.Config.AuthorizationEndpoint = info.AuthorizationEndpoint
+	c.Config.UaaEndpoint = info.TokenEndpoint
+	c.Config.DopplerEndPoint = info.DopplerEndpoint
+	c.Config.SSHOAuthClient = info.AppSSHOAuthClient
+	return nil
+}
+
+// Target selects the org and space used to resolve app names.
+func (c *Connection) Target(org, space string) error {
+	orgGUID, err := c.OrgGUID(org)
+	if err != nil {
+		return err
+	}
+	spaceGUID, err := c.SpaceGUID(orgGUID, space)
+	if err != nil {
+		return err
+	}
+	c.Config.OrganizationFields.GUID = orgGUID
+	c.Config.OrganizationFields.Name = org
+	c.Config.SpaceFields.GUID = spaceGUID
+	c.Config.SpaceFields.Name = space
+	return nil
+}
+
+func (c *Connection) Info() (*Info, error) {
+	response, err := c.HTTP.Get(strings.TrimSuffix(c.Config.Target, "/") + "/v2/info")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected '%s' from: GET %s", response.Status, response.Request.URL)
+	}
+	info := &Info{}
+	if err := json.NewDecoder(response.Body).Decode(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *Connection) OrgGUID(org string) (string, error) {
+	return c.findGUID("organization", "/v2/organizations", url.Values{
+		"q": {"name:" + org},
+	})
+}
+
+func (c *Connection) SpaceGUID(orgGUID, space string) (string, error) {
+	return c.findGUID("space", "/v2/spaces", url.Values{
+		"q": {"name:" + space, "organization_guid:" + orgGUID},
+	})
+}
+
+// AppGUID resolves the GUID of an app in any space, without changing the
+// targeted space.
+func (c *Connection) AppGUID(org, space, name string) (string, error) {
+	orgGUID, err := c.OrgGUID(org)
+	if err != nil {
+		return "", err
+	}
+	spaceGUID, err := c.SpaceGUID(orgGUID, space)
+	if err != nil {
+		return "", err
+	}
+	return c.findGUID("app", "/v2/apps", url.Values{
+		"q": {"name:" + name, "space_guid:" + spaceGUID},
+	})
+}
+
+func (c *Connection) findGUID(kind, endpoint string, query url.Values) (string, error) {
+	var result struct {
+		Resources []struct {
+			Metadata struct {
+				GUID string `json:"guid"`
+			} `json:"metadata"`
+		} `json:"resources"`
+	}
+	if err := c.getJSON(endpoint+"?"+query.Encode(), &result); err != nil {
+		return "", err
+	}
+	if len(result.Resources) == 0 {
+		return "", fmt.Errorf("%s %s not found", kind, strings.TrimPrefix(query["q"][0], "name:"))
+	}
+	return result.Resources[0].Metadata.GUID, nil
+}
+
+func (c *Connection) CliCommandWithoutTerminalOutput(args ...string) ([]string, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) CliCommand(args ...string) ([]string, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) GetCurrentOrg() (plugin_models.Organization, error) {
+	var org plugin_models.Organization
+	org.Guid = c.Config.OrganizationFields.GUID
+	org.Name = c.Config.OrganizationFields.Name
+	return org, nil
+}
+
+func (c *Connection) GetCurrentSpace() (plugin_models.Space, error) {
+	var space plugin_models.Space
+	space.Guid = c.Config.SpaceFields.GUID
+	space.Name = c.Config.SpaceFields.Name
+	return space, nil
+}
+
+func (c *Connection) Username() (string, error) {
+	claims, err := c.claims()
+	return claims.UserName, err
+}
+
+func (c *Connection) UserGuid() (string, error) {
+	claims, err := c.claims()
+	return claims.UserID, err
+}
+
+func (c *Connection) UserEmail() (string, error) {
+	claims, err := c.claims()
+	return claims.Email, err
+}
+
+func (c *Connection) IsLoggedIn() (bool, error) {
+	return c.Config.AccessToken != "" || c.canRenew(), nil
+}
+
+func (c *Connection) IsSSLDisabled() (bool, error) {
+	return c.Config.SSLDisabled, nil
+}
+
+func (c *Connection) HasOrganization() (bool, error) {
+	return c.Config.OrganizationFields.GUID != "", nil
+}
+
+func (c *Connection) HasSpace() (bool, error) {
+	return c.Config.SpaceFields.GUID != "", nil
+}
+
+func (c *Connection) ApiEndpoint() (string, error) {
+	return c.Config.Target, nil
+}
+
+func (c *Connection) ApiVersion() (string, error) {
+	return c.Config.APIVersion, nil
+}
+
+func (c *Connection) HasAPIEndpoint() (bool, error) {
+	return c.Config.Target != "", nil
+}
+
+func (c *Connection) LoggregatorEndpoint() (string, error) {
+	return c.Config.LoggregatorEndPoint, nil
+}
+
+func (c *Connection) DopplerEndpoint() (string, error) {
+	return c.Config.DopplerEndPoint, nil
+}
+
+// AccessToken returns the current access token, renewing it first if it
+// has expired or is about to expire.
+func (c *Connection) AccessToken() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.canRenew() || (c.Config.AccessToken != "" && !c.expiring()) {
+		return c.Config.AccessToken, nil
+	}
+	if err := c.renewToken(); err != nil {
+		return "", err
+	}
+	return c.Config.AccessToken, nil
+}
+
+// RefreshAccessToken renews the access token, even if it has not expired.
+func (c *Connection) RefreshAccessToken() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.canRenew() {
+		return "", errNoCredentials
+	}
+	if err := c.renewToken(); err != nil {
+		return "", err
+	}
+	return c.Config.AccessToken, nil
+}
+
+// expiring returns false for tokens without a readable expiration time,
+// since they cannot be renewed preemptively.
+func (c *Connection) expiring() bool {
+	exp, err := expiry(c.Config.AccessToken)
+	return err == nil && time.Until(exp) < tokenExpiryMargin
+}
+
+func (c *Connection) canRenew() bool {
+	return c.Config.RefreshToken != "" || (c.Credentials != nil && c.Credentials.ClientID != "")
+}
+
+func (c *Connection) renewToken() error {
+	uaa := &UAA{Endpoint: c.Config.UaaEndpoint, HTTP: c.HTTP}
+	if uaa.Endpoint == "" {
+		uaa.Endpoint = c.Config.AuthorizationEndpoint
+	}
+	uaa.ClientID, uaa.ClientSecret = c.Config.UAAOAuthClient, c.Config.UAAOAuthClientSecret
+	if c.Credentials != nil && c.Credentials.ClientID != "" {
+		uaa.ClientID, uaa.ClientSecret = c.Credentials.ClientID, c.Credentials.ClientSecret
+	}
+	token, err := c.requestToken(uaa)
+	if err == errNoCredentials {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to renew access token: %s", err)
+	}
+	c.Config.AccessToken = token.Authorization()
+	if token.RefreshToken != "" {
+		c.Config.RefreshToken = token.RefreshToken
+	}
+	return c.saveTokens()
+}
+
+// requestToken obtains a token with the current refresh token. If that token
+// is rejected, for example because a cached refresh token was revoked, the
+// credentials are used instead.
+func (c *Connection) requestToken(uaa *UAA) (*Token, error) {
+	err := errNoCredentials
+	if c.Config.RefreshToken != "" {
+		var token *Token
+		if token, err = uaa.Refresh(c.Config.RefreshToken); err == nil {
+			return token, nil
+		}
+	}
+	switch {
+	case c.Credentials == nil:
+		return nil, err
+	case c.Credentials.RefreshToken != "":
+		if c.Credentials.RefreshToken == c.Config.RefreshToken {
+			return nil, err
+		}
+		c.Config.RefreshToken = c.Credentials.RefreshToken
+		return uaa.Refresh(c.Credentials.RefreshToken)
+	case c.Credentials.ClientID != "":
+		c.Config.RefreshToken = ""
+		return uaa.ClientCredentials()
+	}
+	return nil, err
+}
+
+func (c *Connection) saveTokens() error {
+	if c.TokenPath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.TokenPath), 0700); err != nil {
+		return err
+	}
+	tokenFile, err := os.OpenFile(c.TokenPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer tokenFile.Close()
+	return json.NewEncoder(tokenFile).Encode(tokenCache{
+		Target:       c.Config.Target,
+		AccessToken:  c.Config.AccessToken,
+		RefreshToken: c.Config.RefreshToken,
+	})
+}
+
+func (c *Connection) GetApp(name string) (plugin_models.GetAppModel, error) {
+	var app plugin_models.GetAppModel
+	if c.Config.SpaceFields.GUID == "" {
+		return app, errors.New("no space targeted")
+	}
+	query := url.Values{}
+	query.Add("q", "name:"+name)
+	query.Add("q", "space_guid:"+c.Config.SpaceFields.GUID)
+
+	var result struct {
+		Resources []struct {
+			Metadata struct {
+				GUID string `json:"guid"`
+			} `json:"metadata"`
+			Entity struct {
+				Name                 string                 `json:"name"`
+				Command              string                 `json:"command"`
+				DetectedStartCommand string                 `json:"detected_start_command"`
+				DiskQuota            int64                  `json:"disk_quota"`
+				Memory               int64                  `json:"memory"`
+				Instances            int                    `json:"instances"`
+				State                string                 `json:"state"`
+				SpaceGUID            string                 `json:"space_guid"`
+				Environment          map[string]interface{} `json:"environment_json"`
+			} `json:"entity"`
+		} `json:"resources"`
+	}
+	if err := c.getJSON("/v2/apps?"+query.Encode(), &result); err != nil {
+		return app, err
+	}
+	if len(result.Resources) == 0 {
+		return app, fmt.Errorf("app %s not found", name)
+	}
+	resource := result.Resources[0]
+	app.Guid = resource.Metadata.GUID
+	app.Name = resource.Entity.Name
+	app.Command = resource.Entity.Command
+	app.DetectedStartCommand = resource.Entity.DetectedStartCommand
+	app.DiskQuota = resource.Entity.DiskQuota
+	app.Memory = resource.Entity.Memory
+	app.InstanceCount = resource.Entity.Instances
+	app.State = resource.Entity.State
+	app.SpaceGuid = resource.Entity.SpaceGUID
+	app.EnvironmentVars = resource.Entity.Environment
+	return app, nil
+}
+
+func (c *Connection) GetApps() ([]plugin_models.GetAppsModel, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) GetOrgs() ([]plugin_models.GetOrgs_Model, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) GetSpaces() ([]plugin_models.GetSpaces_Model, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) GetOrgUsers(string, ...string) ([]plugin_models.GetOrgUsers_Model, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) GetSpaceUsers(string, string) ([]plugin_models.GetSpaceUsers_Model, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) GetServices() ([]plugin_models.GetServices_Model, error) {
+	return nil, ErrCLIRequired
+}
+
+func (c *Connection) GetService(string) (plugin_models.GetService_Model, error) {
+	return plugin_models.GetService_Model{}, ErrCLIRequired
+}
+
+func (c *Connection) GetOrg(string) (plugin_models.GetOrg_Model, error) {
+	return plugin_models.GetOrg_Model{}, ErrCLIRequired
+}
+
+func (c *Connection) GetSpace(string) (plugin_models.GetSpace_Model, error) {
+	return plugin_models.GetSpace_Model{}, ErrCLIRequired
+}
+
+func (c *Connection) getJSON(endpoint string, v interface{}) error {
+	token, err := c.AccessToken()
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("must be authenticated with Cloud Foundry API")
+	}
+	request, err := http.NewRequest("GET", strings.TrimSuffix(c.Config.Target, "/")+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", token)
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected '%s' from: GET %s", response.Status, request.URL)
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+type tokenClaims struct {
+	UserName string `json:"user_name"`
+	UserID   string `json:"user_id"`
+	Email    string `json:"email"`
+	Expiry   int64  `json:"exp"`
+}
+
+func (c *Connection) claims() (*tokenClaims, error) {
+	return decodeClaims(c.Config.AccessToken)
+}
+
+func decodeClaims(authorization string) (*tokenClaims, error) {
+	claims := &tokenClaims{}
+	parts := strings.Split(strings.TrimPrefix(authorization, "bearer "), ".")
+	if len(parts) != 3 {
+		return claims, errors.New("invalid access token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims, err
+	}
+	return claims, json.Unmarshal(payload, claims)
+}
@@ -0,0 +1,259 @@
+package cfclient_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cflocal/cfclient"
+)
+
+var _ = Describe("Connection", func() {
+	var (
+		tempDir    string
+		configPath string
+		connection *Connection
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "cflocal.cfclient")
+		Expect(err).NotTo(HaveOccurred())
+		configPath = filepath.Join(tempDir, "config.json")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	writeConfig := func(target string) {
+		Expect(ioutil.WriteFile(configPath, []byte(`{
+			"Target": "`+target+`",
+			"APIVersion": "some-api-version",
+			"DopplerEndPoint": "wss://some-doppler",
+			"AccessToken": "bearer some-header.eyJ1c2VyX25hbWUiOiJzb21lLXVzZXIiLCJ1c2VyX2lkIjoic29tZS1ndWlkIiwiZW1haWwiOiJzb21lQGVtYWlsIn0.some-signature",
+			"OrganizationFields": {"GUID": "some-org-guid", "Name": "some-org"},
+			"SpaceFields": {"GUID": "some-space-guid", "Name": "some-space"},
+			"SSLDisabled": true
+		}`), 0666)).To(Succeed())
+		var err error
+		connection, err = NewConnection(configPath)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Describe("#NewConnection", func() {
+		It("should read the cf CLI configuration", func() {
+			writeConfig("some-target")
+
+			Expect(connection.ApiEndpoint()).To(Equal("some-target"))
+			Expect(connection.ApiVersion()).To(Equal("some-api-version"))
+			Expect(connection.DopplerEndpoint()).To(Equal("wss://some-doppler"))
+			Expect(connection.IsLoggedIn()).To(BeTrue())
+			Expect(connection.IsSSLDisabled()).To(BeTrue())
+			Expect(connection.HasSpace()).To(BeTrue())
+
+			org, err := connection.GetCurrentOrg()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org.Guid).To(Equal("some-org-guid"))
+			Expect(org.Name).To(Equal("some-org"))
+
+			space, err := connection.GetCurrentSpace()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(space.Guid).To(Equal("some-space-guid"))
+			Expect(space.Name).To(Equal("some-space"))
+		})
+
+		It("should read the user from the access token", func() {
+			writeConfig("some-target")
+
+			Expect(connection.Username()).To(Equal("some-user"))
+			Expect(connection.UserGuid()).To(Equal("some-guid"))
+			Expect(connection.UserEmail()).To(Equal("some@email"))
+		})
+
+		Context("when the configuration file does not exist", func() {
+			It("should return a connection that is not logged in", func() {
+				connection, err := NewConnection(configPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(connection.IsLoggedIn()).To(BeFalse())
+				Expect(connection.HasAPIEndpoint()).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("#GetApp", func() {
+		It("should return the app from the targeted space", func() {
+			var query []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/v2/apps"))
+				Expect(r.Header.Get("Authorization")).To(HavePrefix("bearer "))
+				query = r.URL.Query()["q"]
+				w.Write([]byte(`{"resources": [{
+					"metadata": {"guid": "some-app-guid"},
+					"entity": {"name": "some-app", "command": "some-command", "state": "STARTED"}
+				}]}`))
+			}))
+			defer server.Close()
+			writeConfig(server.URL)
+
+			app, err := connection.GetApp("some-app")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(app.Guid).To(Equal("some-app-guid"))
+			Expect(app.Name).To(Equal("some-app"))
+			Expect(app.Command).To(Equal("some-command"))
+			Expect(app.State).To(Equal("STARTED"))
+			Expect(query).To(Equal([]string{"name:some-app", "space_guid:some-space-guid"}))
+		})
+
+		Context("when the app does not exist", func() {
+			It("should return an error", func() {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.Write([]byte(`{"resources": []}`))
+				}))
+				defer server.Close()
+				writeConfig(server.URL)
+
+				_, err := connection.GetApp("some-app")
+				Expect(err).To(MatchError("app some-app not found"))
+			})
+		})
+	})
+
+	Describe("#Login", func() {
+		It("should obtain a token with the client credentials and resolve apps in any space", func() {
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v2/info":
+					w.Write([]byte(`{"token_endpoint": "` + server.URL + `", "app_ssh_oauth_client": "ssh-proxy"}`))
+				case "/oauth/token":
+					Expect(r.ParseForm()).To(Succeed())
+					Expect(r.PostForm.Get("grant_type")).To(Equal("client_credentials"))
+					w.Write([]byte(`{"access_token": "some-token"}`))
+				case "/v2/organizations":
+					Expect(r.Header.Get("Authorization")).To(Equal("bearer some-token"))
+					Expect(r.URL.Query()["q"]).To(Equal([]string{"name:some-org"}))
+					w.Write([]byte(`{"resources": [{"metadata": {"guid": "some-org-guid"}}]}`))
+				case "/v2/spaces":
+					Expect(r.URL.Query()["q"]).To(Equal([]string{"name:some-space", "organization_guid:some-org-guid"}))
+					w.Write([]byte(`{"resources": [{"metadata": {"guid": "some-space-guid"}}]}`))
+				case "/v2/apps":
+					Expect(r.URL.Query()["q"]).To(Equal([]string{"name:some-app", "space_guid:some-space-guid"}))
+					w.Write([]byte(`{"resources": [{"metadata": {"guid": "some-app-guid"}}]}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			connection, err := NewConnection(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(connection.Login(server.URL, &Credentials{
+				ClientID:     "some-client",
+				ClientSecret: "some-secret",
+			})).To(Succeed())
+
+			Expect(connection.ApiEndpoint()).To(Equal(server.URL))
+			Expect(connection.Config.SSHOAuthClient).To(Equal("ssh-proxy"))
+			Expect(connection.AccessToken()).To(Equal("bearer some-token"))
+			Expect(connection.AppGUID("some-org", "some-space", "some-app")).To(Equal("some-app-guid"))
+		})
+	})
+
+	Describe("#NewTargetConnection", func() {
+		var (
+			server        *httptest.Server
+			tokenPath     string
+			refreshTokens []string
+		)
+
+		BeforeEach(func() {
+			refreshTokens = nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v2/info":
+					w.Write([]byte(`{"token_endpoint": "` + server.URL + `"}`))
+				case "/oauth/token":
+					Expect(r.ParseForm()).To(Succeed())
+					refreshToken := r.PostForm.Get("refresh_token")
+					refreshTokens = append(refreshTokens, refreshToken)
+					if refreshToken != "some-env-refresh-token" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					w.Write([]byte(`{"access_token": "some-token", "refresh_token": "some-new-refresh-token"}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			tokenPath = filepath.Join(tempDir, "tokens", "some-target.json")
+			Expect(os.MkdirAll(filepath.Dir(tokenPath), 0700)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		writeTokens := func(target string) {
+			Expect(ioutil.WriteFile(tokenPath, []byte(`{
+				"Target": "`+target+`",
+				"AccessToken": "bearer some-cached-token",
+				"RefreshToken": "some-cached-refresh-token"
+			}`), 0600)).To(Succeed())
+		}
+
+		It("should use the cached tokens for the same API", func() {
+			writeTokens(server.URL)
+			connection, err := NewTargetConnection(server.URL+"/", false, tokenPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(connection.AccessToken()).To(Equal("bearer some-cached-token"))
+			Expect(connection.Config.RefreshToken).To(Equal("some-cached-refresh-token"))
+		})
+
+		It("should ignore cached tokens for a different API", func() {
+			writeTokens("https://some-other-api")
+			connection, err := NewTargetConnection(server.URL, false, tokenPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(connection.IsLoggedIn()).To(BeFalse())
+			Expect(connection.Config.RefreshToken).To(BeEmpty())
+		})
+
+		It("should use the credentials when the cached refresh token is rejected", func() {
+			writeTokens(server.URL)
+			connection, err := NewTargetConnection(server.URL, false, tokenPath)
+			Expect(err).NotTo(HaveOccurred())
+			connection.Credentials = &Credentials{RefreshToken: "some-env-refresh-token"}
+
+			Expect(connection.RefreshAccessToken()).To(Equal("bearer some-token"))
+			Expect(refreshTokens).To(Equal([]string{"some-cached-refresh-token", "some-env-refresh-token"}))
+			tokens, err := ioutil.ReadFile(tokenPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokens).To(MatchJSON(`{
+				"Target": "` + server.URL + `",
+				"AccessToken": "bearer some-token",
+				"RefreshToken": "some-new-refresh-token"
+			}`))
+		})
+
+		It("should return an error when the cached refresh token is rejected without credentials", func() {
+			writeTokens(server.URL)
+			connection, err := NewTargetConnection(server.URL, false, tokenPath)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = connection.RefreshAccessToken()
+			Expect(err).To(MatchError(ContainSubstring("failed to renew access token: unexpected '401 Unauthorized'")))
+		})
+	})
+
+	Describe("#CliCommand", func() {
+		It("should return an error", func() {
+			writeConfig("some-target")
+			_, err := connection.CliCommand("some-command")
+			Expect(err).To(Equal(ErrCLIRequired))
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package cfclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrUnauthorized = errors.New("access token rejected by UAA")
+
+type UAA struct {
+	Endpoint     string
+	ClientID     string
+	ClientSecret string
+	HTTP         *http.Client
+}
+
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Authorization returns the token in the format expected by the Cloud
+// Controller Authorization header.
+func (t *Token) Authorization() string {
+	return "bearer " + t.AccessToken
+}
+
+func (u *UAA) ClientCredentials() (*Token, error) {
+	return u.token(url.Values{
+		"grant_type": {"client_credentials"},
+	})
+}
+
+func (u *UAA) Refresh(refreshToken string) (*Token, error) {
+	return u.token(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+func (u *UAA) token(form url.Values) (*Token, error) {
+	request, err := http.NewRequest("POST", u.url("/oauth/token"), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(u.clientID(), u.ClientSecret)
+
+	response, err := u.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected '%s' from: POST %s", response.Status, request.URL)
+	}
+	token := &Token{}
+	if err := json.NewDecoder(response.Body).Decode(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// SSHCode requests a one-time authorization code for the SSH proxy, which
+// is equivalent to running `cf ssh-code`.
+func (u *UAA) SSHCode(authorization, sshClientID string) (string, error) {
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {sshClientID},
+	}
+	request, err := http.NewRequest("GET", u.url("/oauth/authorize")+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", authorization)
+
+	client := *u.HTTP
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusUnauthorized {
+		return "", ErrUnauthorized
+	}
+	if response.StatusCode != http.StatusFound {
+		return "", fmt.Errorf("unexpected '%s' from: GET %s", response.Status, request.URL)
+	}
+	location, err := response.Location()
+	if err != nil {
+		return "", err
+	}
+	code := location.Query().Get("code")
+	if code == "" {
+		return "", errors.New("no ssh code returned")
+	}
+	return code, nil
+}
+
+func (u *UAA) url(endpoint string) string {
+	return strings.TrimSuffix(u.Endpoint, "/") + endpoint
+}
+
+func (u *UAA) clientID() string {
+	if u.ClientID == "" {
+		return "cf"
+	}
+	return u.ClientID
+}
+
+// expiry returns the expiration time encoded in a bearer token.
+func expiry(authorization string) (time.Time, error) {
+	claims, err := decodeClaims(authorization)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(claims.Expiry, 0), nil
+}
@@ -0,0 +1,81 @@
+package cfclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cflocal/cfclient"
+)
+
+var _ = Describe("UAA", func() {
+	var (
+		server *httptest.Server
+		form   map[string][]string
+		user   string
+		uaa    *UAA
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/oauth/token":
+				Expect(r.Method).To(Equal("POST"))
+				Expect(r.ParseForm()).To(Succeed())
+				form = r.PostForm
+				user, _, _ = r.BasicAuth()
+				w.Write([]byte(`{"access_token": "some-access-token", "refresh_token": "some-refresh-token", "token_type": "bearer"}`))
+			case "/oauth/authorize":
+				Expect(r.Header.Get("Authorization")).To(Equal("bearer some-token"))
+				http.Redirect(w, r, "/login?code=some-code", http.StatusFound)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		uaa = &UAA{Endpoint: server.URL, HTTP: &http.Client{}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("#ClientCredentials", func() {
+		It("should return a token for the client", func() {
+			uaa.ClientID = "some-client"
+			uaa.ClientSecret = "some-secret"
+			token, err := uaa.ClientCredentials()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.Authorization()).To(Equal("bearer some-access-token"))
+			Expect(form["grant_type"]).To(Equal([]string{"client_credentials"}))
+			Expect(user).To(Equal("some-client"))
+		})
+	})
+
+	Describe("#Refresh", func() {
+		It("should return a new token using the cf client by default", func() {
+			token, err := uaa.Refresh("some-old-refresh-token")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.AccessToken).To(Equal("some-access-token"))
+			Expect(token.RefreshToken).To(Equal("some-refresh-token"))
+			Expect(form["grant_type"]).To(Equal([]string{"refresh_token"}))
+			Expect(form["refresh_token"]).To(Equal([]string{"some-old-refresh-token"}))
+			Expect(user).To(Equal("cf"))
+		})
+	})
+
+	Describe("#SSHCode", func() {
+		It("should return the authorization code from the redirect", func() {
+			Expect(uaa.SSHCode("bearer some-token", "ssh-proxy")).To(Equal("some-code"))
+		})
+
+		Context("when UAA does not redirect", func() {
+			It("should return an error", func() {
+				uaa.Endpoint = server.URL + "/some-path"
+				_, err := uaa.SSHCode("bearer some-token", "ssh-proxy")
+				Expect(err).To(MatchError(ContainSubstring("unexpected '404 Not Found'")))
+			})
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/buildpack/forge/app"
+	"gopkg.in/yaml.v2"
+)
+
+// Config reads and writes local.yml. App configuration and cflocal options
+// are stored under separate top-level keys, and saving either one leaves
+// the other untouched.
+type Config struct {
+	Path string
+}
+
+// Options contains the cflocal-specific sections of local.yml.
+type Options struct {
+	Targets         map[string]*Target      `yaml:"targets,omitempty"`
+	ProtectedSpaces []string                `yaml:"protected_spaces,omitempty"`
+	Forwarding      map[string]*ForwardRule `yaml:"forwarding,omitempty"`
+	ForwardedPorts  map[string]uint         `yaml:"forwarded_ports,omitempty"`
+	RemoteServices  *RemoteServices         `yaml:"remote_services,omitempty"`
+	Stubs           map[string]*Stub        `yaml:"stubs,omitempty"`
+	Ignore          []string                `yaml:"ignore,omitempty"`
+	DropletStacks   map[string]string       `yaml:"droplet_stacks,omitempty"`
+}
+
+// Target is a named Cloud Foundry API that remote apps may be selected from
+// instead of the API targeted by the cf CLI.
+type Target struct {
+	API               string       `yaml:"api"`
+	Org               string       `yaml:"org"`
+	Space             string       `yaml:"space"`
+	SkipSSLValidation bool         `yaml:"skip_ssl_validation,omitempty"`
+	Credentials       *Credentials `yaml:"credentials,omitempty"`
+}
+
+// ForwardRule describes how to forward the named service instance when its
+// credentials are not recognized. Fields are selected with JSON paths into
+// the credentials, such as $.db.hosts[0].
+type ForwardRule struct {
+	Host      string           `yaml:"host,omitempty"`
+	Port      string           `yaml:"port,omitempty"`
+	Addresses []string         `yaml:"addresses,omitempty"`
+	Forwards  []ForwardAddress `yaml:"forwards,omitempty"`
+}
+
+// ForwardAddress is an address that is replaced wherever it appears in the
+// credentials. Connections are tunneled to To, or to From if To is empty.
+type ForwardAddress struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to,omitempty"`
+}
+
+// RemoteServices limits and adjusts the service bindings imported from
+// remote apps. Only the service instances named in Allow are imported, or
+// all of them if Allow is empty. Overrides replace credential fields,
+// selected with JSON paths, for the named service instances. The values of
+// credential fields named in Redact are hidden from output, in addition to
+// passwords, secrets, tokens, and keys.
+type RemoteServices struct {
+	Allow     []string                          `yaml:"allow,omitempty"`
+	Overrides map[string]map[string]interface{} `yaml:"overrides,omitempty"`
+	Redact    []string                          `yaml:"redact,omitempty"`
+}
+
+// Stub replaces the URL of the named HTTP service instance with the URL of
+// a local stub. In record mode, the stub proxies requests to the service and
+// saves the responses. In replay mode, the stub serves the saved responses.
+// URL is a JSON path to the URL in the credentials, and defaults to $.url.
+type Stub struct {
+	Mode string `yaml:"mode,omitempty"`
+	URL  string `yaml:"url,omitempty"`
+}
+
+// Credentials refer to environment variables containing secrets, so that
+// secrets are never stored in local.yml.
+type Credentials struct {
+	ClientID        string `yaml:"client_id,omitempty"`
+	ClientSecretEnv string `yaml:"client_secret_env,omitempty"`
+	RefreshTokenEnv string `yaml:"refresh_token_env,omitempty"`
+}
+
+func (c *Config) Load() (*app.YAML, error) {
+	localYML := &app.YAML{}
+	if err := c.read(localYML); err != nil {
+		return nil, err
+	}
+	return localYML, nil
+}
+
+func (c *Config) Save(localYML *app.YAML) error {
+	return c.write(localYML)
+}
+
+func (c *Config) LoadOptions() (*Options, error) {
+	options := &Options{}
+	if err := c.read(options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (c *Config) SaveOptions(options *Options) error {
+	return c.write(options)
+}
+
+func (c *Config) read(v interface{}) error {
+	yamlBytes, err := ioutil.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(yamlBytes, v)
+}
+
+// write replaces the top-level keys owned by v, or removes them if they are
+// empty, while preserving all other top-level keys.
+func (c *Config) write(v interface{}) error {
+	var existing yaml.MapSlice
+	if err := c.read(&existing); err != nil {
+		return err
+	}
+	updateBytes, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var update yaml.MapSlice
+	if err := yaml.Unmarshal(updateBytes, &update); err != nil {
+		return err
+	}
+	owned := ownedKeys(v)
+	var merged yaml.MapSlice
+	for _, item := range existing {
+		if !owned[fmt.Sprint(item.Key)] {
+			merged = append(merged, item)
+		}
+	}
+	merged = append(merged, update...)
+
+	yamlBytes, err := yaml.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Path, yamlBytes, 0666)
+}
+
+func ownedKeys(v interface{}) map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.Indirect(reflect.ValueOf(v)).Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		keys[key] = true
+	}
+	return keys
+}
@@ -0,0 +1,147 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/buildpack/forge"
+	"github.com/buildpack/forge/app"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cflocal/config"
+)
+
+var _ = Describe("Config", func() {
+	var (
+		tempDir string
+		config  *Config
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "cflocal.config")
+		Expect(err).NotTo(HaveOccurred())
+		config = &Config{Path: filepath.Join(tempDir, "local.yml")}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	Context("when local.yml does not exist", func() {
+		It("should load empty configuration", func() {
+			Expect(config.Load()).To(Equal(&app.YAML{}))
+			Expect(config.LoadOptions()).To(Equal(&Options{}))
+		})
+	})
+
+	Describe("#LoadOptions", func() {
+		It("should load the cflocal options", func() {
+			Expect(ioutil.WriteFile(config.Path, []byte(`
+applications:
+- name: some-app
+targets:
+  some-target:
+    api: some-api
+    org: some-org
+    space: some-space
+    credentials:
+      client_id: some-client
+      client_secret_env: SOME_SECRET
+forwarding:
+  some-service:
+    host: $.db.host
+    port: $.db.port
+    addresses:
+    - $.replicas[0]
+    forwards:
+    - from: some-host:1000
+      to: some-other-host:1000
+remote_services:
+  allow: [some-service]
+  overrides:
+    some-service:
+      $.db.password: some-local-password
+  redact: [some-field]
+stubs:
+  some-api:
+    mode: record
+    url: $.api.url
+ignore:
+- node_modules
+`), 0666)).To(Succeed())
+
+			Expect(config.LoadOptions()).To(Equal(&Options{
+				Targets: map[string]*Target{
+					"some-target": {
+						API:   "some-api",
+						Org:   "some-org",
+						Space: "some-space",
+						Credentials: &Credentials{
+							ClientID:        "some-client",
+							ClientSecretEnv: "SOME_SECRET",
+						},
+					},
+				},
+				Forwarding: map[string]*ForwardRule{
+					"some-service": {
+						Host:      "$.db.host",
+						Port:      "$.db.port",
+						Addresses: []string{"$.replicas[0]"},
+						Forwards:  []ForwardAddress{{From: "some-host:1000", To: "some-other-host:1000"}},
+					},
+				},
+				RemoteServices: &RemoteServices{
+					Allow: []string{"some-service"},
+					Overrides: map[string]map[string]interface{}{
+						"some-service": {"$.db.password": "some-local-password"},
+					},
+					Redact: []string{"some-field"},
+				},
+				Stubs: map[string]*Stub{
+					"some-api": {Mode: "record", URL: "$.api.url"},
+				},
+				Ignore: []string{"node_modules"},
+			}))
+		})
+	})
+
+	Describe("#Save", func() {
+		It("should save the app configuration without changing the cflocal options", func() {
+			options := &Options{
+				Targets: map[string]*Target{
+					"some-target": {API: "some-api", Org: "some-org", Space: "some-space"},
+				},
+			}
+			Expect(config.SaveOptions(options)).To(Succeed())
+			localYML := &app.YAML{
+				Applications: []*forge.AppConfig{{Name: "some-app", Command: "some-command"}},
+			}
+			Expect(config.Save(localYML)).To(Succeed())
+
+			Expect(config.Load()).To(Equal(localYML))
+			Expect(config.LoadOptions()).To(Equal(options))
+		})
+	})
+
+	Describe("#SaveOptions", func() {
+		It("should save the cflocal options without changing the app configuration", func() {
+			localYML := &app.YAML{
+				Applications: []*forge.AppConfig{{Name: "some-app", Command: "some-command"}},
+			}
+			Expect(config.Save(localYML)).To(Succeed())
+			options := &Options{
+				Targets: map[string]*Target{
+					"some-target": {API: "some-api", Org: "some-org", Space: "some-space"},
+				},
+			}
+			Expect(config.SaveOptions(options)).To(Succeed())
+			Expect(config.SaveOptions(&Options{})).To(Succeed())
+
+			Expect(config.Load()).To(Equal(localYML))
+			Expect(config.LoadOptions()).To(Equal(&Options{}))
+		})
+	})
+})
@@ -16,6 +16,22 @@ func (f *FS) WriteFile(path string) (io.WriteCloser, error) {
 	return os.Create(path)
 }
 
+func (f *FS) AppendFile(path string) (io.WriteCloser, int64, error) {
+	file, size, err := f.openFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, 0, err
+	}
+	return file, size, nil
+}
+
+func (f *FS) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (f *FS) Remove(path string) error {
+	return os.Remove(path)
+}
+
 type ReadResetWriteCloser interface {
 	io.ReadWriteCloser
 	Reset() error
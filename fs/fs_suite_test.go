@@ -0,0 +1,13 @@
+package fs_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFS(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "FS Suite")
+}
@@ -0,0 +1,164 @@
+package fs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultIgnore lists the patterns that cf push always ignores.
+var DefaultIgnore = []string{".cfignore", "/manifest.yml", ".gitignore", ".git", ".hg", ".svn", "_darcs", ".DS_Store"}
+
+// Ignore matches app files against .cfignore patterns, with the same
+// semantics as cf push. Patterns are globs where * and ? do not match /,
+// and ** matches any path. Patterns that start with / only match from the
+// app directory, and other patterns match at any depth. Patterns that match
+// a directory also match its contents. Patterns that start with ! include
+// files that earlier patterns ignore.
+type Ignore struct {
+	dir      string
+	patterns []ignorePattern
+	negated  bool
+}
+
+type ignorePattern struct {
+	exclude bool
+	regexp  *regexp.Regexp
+}
+
+// NewIgnore returns an Ignore for DefaultIgnore followed by the patterns.
+// The dir is the app directory, or empty if the app is not a directory.
+func NewIgnore(dir string, patterns ...string) *Ignore {
+	i := &Ignore{dir: dir}
+	for _, line := range append(append([]string{}, DefaultIgnore...), patterns...) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		exclude := true
+		if strings.HasPrefix(line, "!") {
+			line = line[1:]
+			exclude = false
+			i.negated = true
+		}
+		i.patterns = append(i.patterns, ignorePattern{exclude, regexp.MustCompile(globRegexp(path.Clean(line)))})
+	}
+	return i
+}
+
+// Ignore returns an Ignore for the .cfignore file in the app directory, if
+// the path is a directory containing one, followed by the patterns.
+func (f *FS) Ignore(path string, patterns ...string) (*Ignore, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return NewIgnore("", patterns...), nil
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(path, ".cfignore"))
+	if os.IsNotExist(err) {
+		return NewIgnore(path, patterns...), nil
+	} else if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(contents), "\n")
+	return NewIgnore(path, append(lines, patterns...)...), nil
+}
+
+// Match returns true if the path, relative to the app directory and
+// separated by /, is ignored. A nil Ignore matches nothing.
+func (i *Ignore) Match(path string) bool {
+	if i == nil {
+		return false
+	}
+	ignored := false
+	for _, pattern := range i.patterns {
+		if pattern.regexp.MatchString(path) {
+			ignored = pattern.exclude
+		}
+	}
+	return ignored
+}
+
+// Excludes returns regular expressions that match the ignored paths, for
+// excluding them from the app tarball. When patterns are negated, the app
+// directory is searched for the ignored files.
+func (i *Ignore) Excludes() ([]string, error) {
+	if i == nil {
+		return nil, nil
+	}
+	if !i.negated {
+		var excludes []string
+		for _, pattern := range i.patterns {
+			excludes = append(excludes, pattern.regexp.String())
+		}
+		return excludes, nil
+	}
+	if i.dir == "" {
+		return nil, errors.New("ignore patterns starting with ! require an app directory")
+	}
+	var excludes []string
+	err := filepath.Walk(i.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(i.dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !info.IsDir() && i.Match(rel) {
+			excludes = append(excludes, "^"+regexp.QuoteMeta(rel)+"$")
+		}
+		return nil
+	})
+	return excludes, err
+}
+
+// matchPath returns true if the path, which is inside dir, is ignored.
+func (i *Ignore) matchPath(dir, path string) bool {
+	if i == nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return i.Match(filepath.ToSlash(rel))
+}
+
+// skipDir returns true if nothing in the directory at path, which is
+// inside dir, can be included.
+func (i *Ignore) skipDir(dir, path string) bool {
+	return i != nil && !i.negated && i.matchPath(dir, path)
+}
+
+// globRegexp translates a pattern into a regular expression that matches
+// the pattern and the contents of any directory it matches.
+func globRegexp(pattern string) string {
+	prefix := "(?:.*/)?"
+	if strings.HasPrefix(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+		prefix = ""
+	}
+	var glob strings.Builder
+	for len(pattern) > 0 {
+		switch {
+		case strings.HasPrefix(pattern, "**"):
+			glob.WriteString(".*")
+			pattern = pattern[2:]
+		case pattern[0] == '*':
+			glob.WriteString("[^/]*")
+			pattern = pattern[1:]
+		case pattern[0] == '?':
+			glob.WriteString("[^/]")
+			pattern = pattern[1:]
+		default:
+			glob.WriteString(regexp.QuoteMeta(pattern[:1]))
+			pattern = pattern[1:]
+		}
+	}
+	return "^" + prefix + glob.String() + "(?:/.*)?$"
+}
@@ -0,0 +1,135 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cflocal/fs"
+)
+
+var _ = Describe("Ignore", func() {
+	Describe("#Match", func() {
+		It("should ignore the default patterns", func() {
+			ignore := NewIgnore("")
+			Expect(ignore.Match(".git")).To(BeTrue())
+			Expect(ignore.Match(".git/config")).To(BeTrue())
+			Expect(ignore.Match("some-dir/.DS_Store")).To(BeTrue())
+			Expect(ignore.Match("manifest.yml")).To(BeTrue())
+			Expect(ignore.Match("some-dir/manifest.yml")).To(BeFalse())
+			Expect(ignore.Match("some-file")).To(BeFalse())
+		})
+
+		It("should match globs at any depth unless they start with /", func() {
+			ignore := NewIgnore("", "node_modules", "*.log", "/tmp", "docs/**/*.md", "file?.txt")
+			Expect(ignore.Match("node_modules/some-module/index.js")).To(BeTrue())
+			Expect(ignore.Match("some-dir/node_modules")).To(BeTrue())
+			Expect(ignore.Match("some.log")).To(BeTrue())
+			Expect(ignore.Match("some-dir/some.log")).To(BeTrue())
+			Expect(ignore.Match("tmp/some-file")).To(BeTrue())
+			Expect(ignore.Match("some-dir/tmp/some-file")).To(BeFalse())
+			Expect(ignore.Match("docs/some-dir/some.md")).To(BeTrue())
+			Expect(ignore.Match("docs/some.md")).To(BeFalse())
+			Expect(ignore.Match("file1.txt")).To(BeTrue())
+			Expect(ignore.Match("file10.txt")).To(BeFalse())
+			Expect(ignore.Match("some-node_modules")).To(BeFalse())
+		})
+
+		It("should include files that match negated patterns", func() {
+			ignore := NewIgnore("", "*.log", "!important.log")
+			Expect(ignore.Match("some.log")).To(BeTrue())
+			Expect(ignore.Match("some-dir/important.log")).To(BeFalse())
+		})
+
+		It("should not match anything when nil", func() {
+			var ignore *Ignore
+			Expect(ignore.Match(".git")).To(BeFalse())
+		})
+	})
+
+	Describe("#Excludes", func() {
+		It("should return regular expressions for the patterns", func() {
+			excludes, err := NewIgnore("", "/tmp").Excludes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(excludes).To(ContainElement(`^tmp(?:/.*)?$`))
+			Expect(matchAny(excludes, "tmp/some-file")).To(BeTrue())
+			Expect(matchAny(excludes, ".git/config")).To(BeTrue())
+			Expect(matchAny(excludes, "some-file")).To(BeFalse())
+		})
+
+		Context("when patterns are negated", func() {
+			var tempDir string
+
+			BeforeEach(func() {
+				var err error
+				tempDir, err = ioutil.TempDir("", "cflocal.fs")
+				Expect(err).NotTo(HaveOccurred())
+				for _, path := range []string{"some.log", "logs/important.log", "logs/other.log", "some-file"} {
+					Expect(os.MkdirAll(filepath.Dir(filepath.Join(tempDir, path)), 0777)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(tempDir, path), nil, 0666)).To(Succeed())
+				}
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(tempDir)).To(Succeed())
+			})
+
+			It("should return regular expressions for the ignored files in the app directory", func() {
+				excludes, err := NewIgnore(tempDir, "*.log", "!important.log").Excludes()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(excludes).To(ConsistOf(`^logs/other\.log$`, `^some\.log$`))
+			})
+
+			It("should return an error without an app directory", func() {
+				_, err := NewIgnore("", "*.log", "!important.log").Excludes()
+				Expect(err).To(MatchError("ignore patterns starting with ! require an app directory"))
+			})
+		})
+	})
+})
+
+var _ = Describe("FS", func() {
+	Describe("#Ignore", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "cflocal.fs")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tempDir)).To(Succeed())
+		})
+
+		It("should read .cfignore from the app directory and add the patterns", func() {
+			Expect(ioutil.WriteFile(filepath.Join(tempDir, ".cfignore"), []byte("node_modules\n\n*.log\n"), 0666)).To(Succeed())
+			ignore, err := (&FS{}).Ignore(tempDir, "/tmp")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ignore.Match("node_modules/some-module")).To(BeTrue())
+			Expect(ignore.Match("some.log")).To(BeTrue())
+			Expect(ignore.Match("tmp")).To(BeTrue())
+			Expect(ignore.Match("some-file")).To(BeFalse())
+		})
+
+		It("should only use the patterns when the app is not a directory", func() {
+			ignore, err := (&FS{}).Ignore(filepath.Join(tempDir, "some-app.zip"), "*.log")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ignore.Match("some.log")).To(BeTrue())
+			Expect(ignore.Match("node_modules")).To(BeFalse())
+		})
+	})
+})
+
+func matchAny(excludes []string, path string) bool {
+	for _, exclude := range excludes {
+		if regexp.MustCompile(exclude).MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
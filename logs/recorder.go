@@ -0,0 +1,223 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultKeep      = 10
+	fileTimeFormat   = "20060102T150405.000000000Z"
+	followInterval   = 250 * time.Millisecond
+	lineTimeFormat   = time.RFC3339Nano
+	logFileExtension = ".log"
+)
+
+var colorPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Recorder copies the output of local containers into timestamped log
+// files, stored as <Dir>/<app name>/<start time>.log. Only the newest Keep
+// log files are retained for each app.
+type Recorder struct {
+	Dir  string
+	Keep int
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// Tee returns a writer that writes to out and, while a log file is being
+// recorded, to the log file.
+func (r *Recorder) Tee(out io.Writer) io.Writer {
+	return &teeWriter{recorder: r, out: out}
+}
+
+// Record starts a new log file for the named app. Output written to the
+// writers returned by Tee is recorded until the returned closer is closed.
+func (r *Recorder) Record(name string) (io.Closer, error) {
+	dir := filepath.Join(r.Dir, name)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	filename := time.Now().UTC().Format(fileTimeFormat) + logFileExtension
+	file, err := os.Create(filepath.Join(dir, filename))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.rotate(dir); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.file = file
+	return recording{r, file}, nil
+}
+
+type recording struct {
+	recorder *Recorder
+	file     *os.File
+}
+
+func (r recording) Close() error {
+	r.recorder.mutex.Lock()
+	defer r.recorder.mutex.Unlock()
+	if r.recorder.file != r.file {
+		return nil
+	}
+	r.recorder.file = nil
+	return r.file.Close()
+}
+
+func (r *Recorder) writeLine(line []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		return
+	}
+	fmt.Fprintf(r.file, "%s %s\n", time.Now().UTC().Format(lineTimeFormat), colorPattern.ReplaceAll(line, nil))
+}
+
+func (r *Recorder) rotate(dir string) error {
+	files, err := logFiles(dir)
+	if err != nil {
+		return err
+	}
+	keep := r.Keep
+	if keep <= 0 {
+		keep = defaultKeep
+	}
+	for len(files) > keep {
+		if err := os.Remove(filepath.Join(dir, files[0])); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Tail writes the newest log file for the named app to out. If follow is
+// true, lines are written as they are recorded until stop is closed, and
+// newer log files are followed as they are created.
+func (r *Recorder) Tail(name string, out io.Writer, follow bool, stop <-chan struct{}) error {
+	dir := filepath.Join(r.Dir, name)
+	path, err := latest(dir)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("no local logs found for %s", name)
+	}
+	for {
+		next, err := tailFile(path, dir, out, follow, stop)
+		if err != nil || next == "" {
+			return err
+		}
+		path = next
+	}
+}
+
+// tailFile copies the log file to out, and then, if following, continues to
+// copy new lines until stop is closed or a newer log file is created. It
+// returns the path of the newer log file, if any.
+func tailFile(path, dir string, out io.Writer, follow bool, stop <-chan struct{}) (next string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		partial = append(partial, line...)
+		if err == nil {
+			if _, err := out.Write(partial); err != nil {
+				return "", err
+			}
+			partial = nil
+			continue
+		}
+		if err != io.EOF {
+			return "", err
+		}
+		if !follow {
+			if len(partial) > 0 {
+				_, err := out.Write(append(partial, '\n'))
+				return "", err
+			}
+			return "", nil
+		}
+		if newest, err := latest(dir); err != nil {
+			return "", err
+		} else if newest != path && len(partial) == 0 {
+			return newest, nil
+		}
+		select {
+		case <-stop:
+			return "", nil
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+func latest(dir string) (string, error) {
+	files, err := logFiles(dir)
+	if err != nil || len(files) == 0 {
+		return "", err
+	}
+	return filepath.Join(dir, files[len(files)-1]), nil
+}
+
+// logFiles returns the names of the log files in dir, oldest first.
+func logFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), logFileExtension) {
+			files = append(files, info.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+type teeWriter struct {
+	recorder *Recorder
+	out      io.Writer
+	buf      []byte
+}
+
+func (t *teeWriter) Write(p []byte) (n int, err error) {
+	if n, err = t.out.Write(p); err != nil {
+		return n, err
+	}
+	t.buf = append(t.buf, p...)
+	for {
+		i := bytes.IndexByte(t.buf, '\n')
+		if i < 0 {
+			return n, nil
+		}
+		t.recorder.writeLine(bytes.TrimSuffix(t.buf[:i], []byte("\r")))
+		t.buf = t.buf[i+1:]
+	}
+}
@@ -0,0 +1,127 @@
+package logs_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	. "code.cloudfoundry.org/cflocal/logs"
+)
+
+var _ = Describe("Recorder", func() {
+	var (
+		tempDir  string
+		recorder *Recorder
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "cflocal.logs")
+		Expect(err).NotTo(HaveOccurred())
+		recorder = &Recorder{Dir: tempDir, Keep: 2}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	logFiles := func(name string) []string {
+		paths, err := filepath.Glob(filepath.Join(tempDir, name, "*.log"))
+		Expect(err).NotTo(HaveOccurred())
+		return paths
+	}
+
+	Describe("#Tee", func() {
+		It("should write to the output and record timestamped lines without color", func() {
+			out := &bytes.Buffer{}
+			tee := recorder.Tee(out)
+			fmt.Fprintln(tee, "not-recorded")
+
+			recording, err := recorder.Record("some-app")
+			Expect(err).NotTo(HaveOccurred())
+			fmt.Fprint(tee, color.New(color.FgGreen).Sprint("[some-app]")+" some-")
+			fmt.Fprint(tee, "line\r\nsome-other-line\n")
+			Expect(recording.Close()).To(Succeed())
+			fmt.Fprintln(tee, "also-not-recorded")
+
+			Expect(out.String()).To(ContainSubstring("not-recorded\n"))
+			Expect(out.String()).To(ContainSubstring("some-other-line\n"))
+			Expect(out.String()).To(ContainSubstring("also-not-recorded\n"))
+
+			paths := logFiles("some-app")
+			Expect(paths).To(HaveLen(1))
+			contents, err := ioutil.ReadFile(paths[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(MatchRegexp(`^\S+ \[some-app\] some-line\n\S+ some-other-line\n$`))
+		})
+	})
+
+	Describe("#Record", func() {
+		It("should keep only the newest log files", func() {
+			for i := 0; i < 4; i++ {
+				recording, err := recorder.Record("some-app")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(recording.Close()).To(Succeed())
+				time.Sleep(time.Millisecond)
+			}
+			Expect(logFiles("some-app")).To(HaveLen(2))
+		})
+	})
+
+	Describe("#Tail", func() {
+		It("should write the newest log file", func() {
+			tee := recorder.Tee(ioutil.Discard)
+			for _, line := range []string{"some-old-line", "some-new-line"} {
+				recording, err := recorder.Record("some-app")
+				Expect(err).NotTo(HaveOccurred())
+				fmt.Fprintln(tee, line)
+				Expect(recording.Close()).To(Succeed())
+				time.Sleep(time.Millisecond)
+			}
+
+			out := &bytes.Buffer{}
+			Expect(recorder.Tail("some-app", out, false, nil)).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`^\S+ some-new-line\n$`))
+		})
+
+		It("should follow new lines and new log files until stopped", func() {
+			tee := recorder.Tee(ioutil.Discard)
+			recording, err := recorder.Record("some-app")
+			Expect(err).NotTo(HaveOccurred())
+			fmt.Fprintln(tee, "some-first-line")
+
+			out := gbytes.NewBuffer()
+			stop := make(chan struct{})
+			done := make(chan error)
+			go func() { done <- recorder.Tail("some-app", out, true, stop) }()
+			Eventually(out).Should(gbytes.Say("some-first-line"))
+
+			fmt.Fprintln(tee, "some-second-line")
+			Eventually(out).Should(gbytes.Say("some-second-line"))
+			Expect(recording.Close()).To(Succeed())
+
+			time.Sleep(time.Millisecond)
+			recording, err = recorder.Record("some-app")
+			Expect(err).NotTo(HaveOccurred())
+			defer recording.Close()
+			fmt.Fprintln(tee, "some-third-line")
+			Eventually(out).Should(gbytes.Say("some-third-line"))
+
+			close(stop)
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should return an error when there are no log files", func() {
+			err := recorder.Tail("some-app", ioutil.Discard, false, nil)
+			Expect(err).To(MatchError("no local logs found for some-app"))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package logs
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	redacted       = "[REDACTED]"
+	maxPartialLine = 64 * 1024
+)
+
+// Redactor hides secret values in output. Secrets may be added after
+// output has started, such as when remote service credentials are
+// retrieved.
+type Redactor struct {
+	mutex   sync.RWMutex
+	secrets []string
+	writers []*redactWriter
+}
+
+// Redact adds secret values to hide.
+func (r *Redactor) Redact(secrets ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, secret := range secrets {
+		if secret != "" && !contains(r.secrets, secret) {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// replace longer secrets first, in case one secret contains another
+	sort.Slice(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+}
+
+// Writer returns a writer that writes to out with secret values replaced.
+// Once there are secrets to hide, output is written a line at a time, so
+// that secrets split across writes are also hidden. Closing the writer
+// writes any incomplete line, without closing out.
+func (r *Redactor) Writer(out io.Writer) io.WriteCloser {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	w := &redactWriter{redactor: r, out: out}
+	r.writers = append(r.writers, w)
+	return w
+}
+
+// Flush writes any incomplete lines held by the writers returned by Writer.
+// The writers may still be used afterwards.
+func (r *Redactor) Flush() error {
+	r.mutex.RLock()
+	writers := r.writers
+	r.mutex.RUnlock()
+	var err error
+	for _, w := range writers {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (r *Redactor) redact(s string) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if len(r.secrets) == 0 {
+		return s, false
+	}
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	return s, true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+type redactWriter struct {
+	redactor *Redactor
+	out      io.Writer
+	mutex    sync.Mutex
+	buf      []byte
+}
+
+func (w *redactWriter) Write(p []byte) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buf = append(w.buf, p...)
+	end := bytes.LastIndexAny(w.buf, "\r\n") + 1
+	if _, active := w.redactor.redact(""); !active || len(w.buf) > maxPartialLine {
+		end = len(w.buf)
+	}
+	if end == 0 {
+		return len(p), nil
+	}
+	output, _ := w.redactor.redact(string(w.buf[:end]))
+	w.buf = append(w.buf[:0], w.buf[end:]...)
+	if _, err := io.WriteString(w.out, output); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *redactWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	output, _ := w.redactor.redact(string(w.buf))
+	w.buf = w.buf[:0]
+	_, err := io.WriteString(w.out, output)
+	return err
+}
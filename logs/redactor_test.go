@@ -0,0 +1,72 @@
+package logs_test
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cflocal/logs"
+)
+
+var _ = Describe("Redactor", func() {
+	var (
+		redactor *Redactor
+		out      *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		redactor = &Redactor{}
+		out = &bytes.Buffer{}
+	})
+
+	Describe("#Writer", func() {
+		It("should write output unchanged when there are no secrets", func() {
+			w := redactor.Writer(out)
+			fmt.Fprint(w, "some-partial-")
+			Expect(out.String()).To(Equal("some-partial-"))
+		})
+
+		It("should replace secrets in each line", func() {
+			w := redactor.Writer(out)
+			redactor.Redact("some-secret", "some-secret-value", "")
+			fmt.Fprint(w, "a some-secret-va")
+			Expect(out.String()).To(BeEmpty())
+			fmt.Fprint(w, "lue b some-secret\nc some-")
+			Expect(out.String()).To(Equal("a [REDACTED] b [REDACTED]\n"))
+			fmt.Fprint(w, "secret d\r")
+			Expect(out.String()).To(Equal("a [REDACTED] b [REDACTED]\nc [REDACTED] d\r"))
+		})
+
+		It("should write the incomplete line when closed", func() {
+			w := redactor.Writer(out)
+			redactor.Redact("some-secret")
+			fmt.Fprint(w, "a\nb some-secret")
+			Expect(out.String()).To(Equal("a\n"))
+			Expect(w.Close()).To(Succeed())
+			Expect(out.String()).To(Equal("a\nb [REDACTED]"))
+			Expect(w.Close()).To(Succeed())
+			Expect(out.String()).To(Equal("a\nb [REDACTED]"))
+		})
+	})
+
+	Describe("#Flush", func() {
+		It("should write the incomplete lines of each writer", func() {
+			otherOut := &bytes.Buffer{}
+			w := redactor.Writer(out)
+			otherW := redactor.Writer(otherOut)
+			redactor.Redact("some-secret")
+			fmt.Fprint(w, "a some-secret")
+			fmt.Fprint(otherW, "b some-secret")
+			Expect(out.String()).To(BeEmpty())
+			Expect(otherOut.String()).To(BeEmpty())
+			Expect(redactor.Flush()).To(Succeed())
+			Expect(out.String()).To(Equal("a [REDACTED]"))
+			Expect(otherOut.String()).To(Equal("b [REDACTED]"))
+
+			fmt.Fprint(w, " c some-secret\n")
+			Expect(out.String()).To(Equal("a [REDACTED] c [REDACTED]\n"))
+		})
+	})
+})
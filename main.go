@@ -3,11 +3,13 @@ package main
 import (
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"github.com/fatih/color"
 	"golang.org/x/crypto/ssh/terminal"
 
+	"code.cloudfoundry.org/cflocal/cfclient"
 	"code.cloudfoundry.org/cflocal/cfplugin"
 	"code.cloudfoundry.org/cflocal/plugin"
 	"code.cloudfoundry.org/cflocal/ui"
@@ -33,6 +35,7 @@ func main() {
 		Err:       os.Stderr,
 		In:        os.Stdin,
 		ErrIsTerm: terminal.IsTerminal(int(os.Stderr.Fd())),
+		JSON:      hasArg(os.Args, "--json"),
 	}
 
 	cflocal := &plugin.Plugin{
@@ -46,7 +49,11 @@ func main() {
 		case "help", "-h", "--help":
 			cflocal.Help(os.Args[0])
 		default:
-			cfplugin.Start(cflocal)
+			if isPluginCall(os.Args) {
+				cfplugin.Start(cflocal)
+			} else {
+				runStandalone(cflocal, os.Args[1:])
+			}
 		}
 		select {
 		case <-exitChan:
@@ -62,3 +69,61 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// The cf CLI always passes its RPC server port as the first argument.
+func isPluginCall(args []string) bool {
+	_, err := strconv.ParseUint(args[1], 10, 16)
+	return err == nil
+}
+
+func runStandalone(cflocal *plugin.Plugin, args []string) {
+	configPath, err := cfclient.ConfigPath()
+	if err != nil {
+		cflocal.RunErr = err
+		return
+	}
+	connection, err := cfclient.NewConnection(configPath)
+	if err != nil {
+		cflocal.RunErr = err
+		return
+	}
+	if err := login(connection); err != nil {
+		cflocal.RunErr = err
+		return
+	}
+	cflocal.Standalone = true
+	cflocal.Run(connection, append([]string{"local"}, args...))
+}
+
+// login authenticates using CFL_* environment variables when they are set,
+// so that pipelines do not need an existing cf CLI configuration.
+func login(connection *cfclient.Connection) error {
+	credentials := &cfclient.Credentials{
+		ClientID:     os.Getenv("CFL_CLIENT_ID"),
+		ClientSecret: os.Getenv("CFL_CLIENT_SECRET"),
+		RefreshToken: os.Getenv("CFL_REFRESH_TOKEN"),
+	}
+	if credentials.ClientID == "" && credentials.RefreshToken == "" {
+		return nil
+	}
+	api := os.Getenv("CFL_API")
+	if api == "" {
+		api = connection.Config.Target
+	}
+	if err := connection.Login(api, credentials); err != nil {
+		return err
+	}
+	if org, space := os.Getenv("CFL_ORG"), os.Getenv("CFL_SPACE"); org != "" && space != "" {
+		return connection.Target(org, space)
+	}
+	return nil
+}
+
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/buildpack/forge/engine"
 	"github.com/onsi/ginkgo"
@@ -12,6 +13,7 @@ type MockUI struct {
 	Err      error
 	Out      *gbytes.Buffer
 	Reply    map[string]string
+	Results  map[string]map[string]interface{}
 	Progress chan engine.Progress
 }
 
@@ -19,6 +21,7 @@ func NewMockUI() *MockUI {
 	return &MockUI{
 		Out:      gbytes.NewBuffer(),
 		Reply:    map[string]string{},
+		Results:  map[string]map[string]interface{}{},
 		Progress: make(chan engine.Progress, 1),
 	}
 }
@@ -32,6 +35,10 @@ func (m *MockUI) Output(format string, args ...interface{}) {
 	fmt.Fprintf(m.Out, format+"\n", args...)
 }
 
+func (m *MockUI) Result(result string, fields map[string]interface{}) {
+	m.Results[result] = fields
+}
+
 func (m *MockUI) Warn(format string, args ...interface{}) {
 	fmt.Fprintf(m.Out, "Warning: "+format+"\n", args...)
 }
@@ -46,7 +53,14 @@ func (m *MockUI) Error(err error) {
 func (m *MockUI) Loading(message string, progress <-chan engine.Progress) error {
 	fmt.Fprintln(m.Out, "Loading: "+message)
 	for p := range progress {
-		m.Progress <- p
+		select {
+		case m.Progress <- p:
+		default:
+		}
 	}
 	return m.Err
 }
+
+func (m *MockUI) Logs(_ string) io.Writer {
+	return m.Out
+}
@@ -3,8 +3,9 @@ package plugin
 import "code.cloudfoundry.org/cflocal/cfplugin"
 
 type Help struct {
-	CLI cfplugin.CliConnection
-	UI  UI
+	CLI        cfplugin.CliConnection
+	UI         UI
+	Standalone bool
 }
 
 func (h *Help) Short() {
@@ -12,6 +13,10 @@ func (h *Help) Short() {
 }
 
 func (h *Help) Long() {
+	if h.Standalone {
+		h.UI.Output("Usage:%s", Usage)
+		return
+	}
 	if _, err := h.CLI.CliCommand("help", "local"); err != nil {
 		h.UI.Error(err)
 	}
@@ -47,6 +47,14 @@ var _ = Describe("Help", func() {
 			Expect(mockUI.Err).NotTo(HaveOccurred())
 		})
 
+		Context("when running without the cf CLI", func() {
+			It("should output the long usage message", func() {
+				help.Standalone = true
+				help.Long()
+				Expect(string(mockUI.Out.Contents())).To(Equal("Usage:" + Usage + "\n"))
+			})
+		})
+
 		Context("when `cf help local` fails", func() {
 			It("should output the error", func() {
 				mockCLI.EXPECT().CliCommand("help", "local").Return(nil, errors.New("some error"))
@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Passphrase provides the passphrase for services snapshots. It is read
+// from CFL_SERVICES_PASSPHRASE, from the output of
+// CFL_SERVICES_PASSPHRASE_COMMAND (such as a command that queries the OS
+// keyring or a password agent), or from the terminal, in that order. The
+// passphrase is only requested once, and must be entered twice at the
+// terminal when it is used to save a snapshot.
+type Passphrase struct {
+	once       sync.Once
+	passphrase string
+	err        error
+}
+
+func (p *Passphrase) Get(confirm bool) (string, error) {
+	p.once.Do(func() {
+		p.passphrase, p.err = readPassphrase(confirm)
+	})
+	return p.passphrase, p.err
+}
+
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("CFL_SERVICES_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if command := os.Getenv("CFL_SERVICES_PASSPHRASE_COMMAND"); command != "" {
+		return commandPassphrase(command)
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("services snapshot passphrase required: set CFL_SERVICES_PASSPHRASE or CFL_SERVICES_PASSPHRASE_COMMAND")
+	}
+	passphrase, err := promptPassphrase(fd, "Services snapshot passphrase: ")
+	if err != nil || !confirm {
+		return passphrase, err
+	}
+	confirmation, err := promptPassphrase(fd, "Confirm services snapshot passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != passphrase {
+		return "", errors.New("services snapshot passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func promptPassphrase(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
+
+func commandPassphrase(command string) (string, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.Command(shell, flag, command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run CFL_SERVICES_PASSPHRASE_COMMAND: %s", err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -4,10 +4,12 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -15,30 +17,37 @@ import (
 	"github.com/buildpack/forge/app"
 	"github.com/buildpack/forge/engine"
 	"github.com/buildpack/forge/engine/docker"
-	"github.com/fatih/color"
 	goversion "github.com/hashicorp/go-version"
 	"github.com/kardianos/osext"
 
 	"code.cloudfoundry.org/cflocal/cf"
 	"code.cloudfoundry.org/cflocal/cf/cmd"
 	"code.cloudfoundry.org/cflocal/cfplugin"
+	"code.cloudfoundry.org/cflocal/config"
 	"code.cloudfoundry.org/cflocal/fs"
+	"code.cloudfoundry.org/cflocal/logs"
 	"code.cloudfoundry.org/cflocal/remote"
+	"code.cloudfoundry.org/cflocal/snapshot"
+	"code.cloudfoundry.org/cflocal/stub"
+	"code.cloudfoundry.org/cflocal/tunnel"
 )
 
 type Plugin struct {
-	UI      UI
-	Version string
-	RunErr  error
-	Exit    <-chan struct{}
+	UI         UI
+	Version    string
+	Standalone bool
+	RunErr     error
+	Exit       <-chan struct{}
 }
 
 type UI interface {
 	Prompt(prompt string) string
 	Output(format string, a ...interface{})
+	Result(result string, fields map[string]interface{})
 	Warn(format string, a ...interface{})
 	Error(err error)
 	Loading(message string, progress <-chan engine.Progress) error
+	Logs(source string) io.Writer
 }
 
 func (p *Plugin) Run(cliConnection cfplugin.CliConnection, args []string) {
@@ -92,30 +101,65 @@ func (p *Plugin) Run(cliConnection cfplugin.CliConnection, args []string) {
 		},
 	}
 
+	recorder := &logs.Recorder{Dir: filepath.Join(".cflocal", "logs")}
+	redactor := &logs.Redactor{}
+
 	stager := forge.NewStager(engine)
-	stager.Logs = color.Output
+	stager.Logs = redactor.Writer(recorder.Tee(p.UI.Logs("stager")))
 
 	runner := forge.NewRunner(engine)
-	runner.Logs = color.Output
+	runner.Logs = redactor.Writer(recorder.Tee(p.UI.Logs("runner")))
 
 	exporter := forge.NewExporter(engine)
 
 	forwarder := forge.NewForwarder(engine)
-	forwarder.Logs = color.Output
+	forwarder.Logs = redactor.Writer(recorder.Tee(p.UI.Logs("forwarder")))
+
+	tunneler := &tunnel.Tunneler{Logs: p.UI.Logs("tunnel")}
+
+	stubber := &stub.Stubber{
+		Dir:  filepath.Join(".cflocal", "stubs"),
+		Logs: redactor.Writer(p.UI.Logs("stub")),
+		IP:   os.Getenv("CFL_STUB_IP"),
+		Host: os.Getenv("CFL_STUB_HOST"),
+	}
+
+	snapshots := &snapshot.Store{
+		Dir:        filepath.Join(".cflocal", "services"),
+		Passphrase: (&Passphrase{}).Get,
+	}
 
 	image := engine.NewImage()
+	jobs := remote.JobConfig{}
+	if interval, ok := durationEnv("CFL_JOB_POLL_INTERVAL"); ok {
+		jobs.PollInterval = interval
+	}
+	if timeout, ok := durationEnv("CFL_JOB_TIMEOUT"); ok {
+		jobs.Timeout = timeout
+	}
 	remoteApp := &remote.App{
-		CLI:  cliConnection,
-		UI:   p.UI,
-		HTTP: ccHTTPClient,
+		CLI:      cliConnection,
+		UI:       p.UI,
+		HTTP:     ccHTTPClient,
+		Retries:  remote.DefaultRetries,
+		Jobs:     jobs,
+		Exit:     p.Exit,
+		LogCache: os.Getenv("CFL_LOG_CACHE"),
 	}
 	sysFS := &fs.FS{}
-	config := &app.Config{
+	localConfig := &config.Config{
 		Path: "./local.yml",
 	}
+	targets := &Targets{
+		Config: localConfig,
+		UI:     p.UI,
+		Jobs:   jobs,
+		Exit:   p.Exit,
+	}
 	help := &Help{
-		CLI: cliConnection,
-		UI:  p.UI,
+		CLI:        cliConnection,
+		UI:         p.UI,
+		Standalone: p.Standalone,
 	}
 	cf := &cf.CF{
 		UI:   p.UI,
@@ -127,41 +171,78 @@ func (p *Plugin) Run(cliConnection cfplugin.CliConnection, args []string) {
 				Image:    image,
 				FS:       sysFS,
 				Help:     help,
-				Config:   config,
+				Config:   localConfig,
+			},
+			&cmd.Logs{
+				UI:        p.UI,
+				RemoteApp: remoteApp,
+				Targets:   targets,
+				LogFiles:  recorder,
+				Help:      help,
+				Exit:      p.Exit,
 			},
 			&cmd.Pull{
 				UI:        p.UI,
 				RemoteApp: remoteApp,
+				Targets:   targets,
 				FS:        sysFS,
 				Help:      help,
-				Config:    config,
+				Config:    localConfig,
 			},
 			&cmd.Push{
 				UI:        p.UI,
 				RemoteApp: remoteApp,
+				Targets:   targets,
 				FS:        sysFS,
 				Help:      help,
-				Config:    config,
+				Config:    localConfig,
 			},
 			&cmd.Run{
 				UI:        p.UI,
 				Runner:    runner,
 				Forwarder: forwarder,
 				RemoteApp: remoteApp,
+				Targets:   targets,
 				Image:     image,
+				LogFiles:  recorder,
+				Snapshots: snapshots,
+				Redactor:  redactor,
+				Stubber:   stubber,
+				Tunneler:  tunneler,
 				FS:        sysFS,
 				Help:      help,
-				Config:    config,
+				Config:    localConfig,
+			},
+			&cmd.Services{
+				UI:        p.UI,
+				RemoteApp: remoteApp,
+				Targets:   targets,
+				Snapshots: snapshots,
+				Help:      help,
+				Config:    localConfig,
 			},
 			&cmd.Stage{
 				UI:        p.UI,
 				Stager:    stager,
 				RemoteApp: remoteApp,
+				Targets:   targets,
 				Image:     image,
+				LogFiles:  recorder,
+				Snapshots: snapshots,
+				Redactor:  redactor,
 				TarApp:    app.Tar,
 				FS:        sysFS,
 				Help:      help,
-				Config:    config,
+				Config:    localConfig,
+			},
+			&cmd.Tunnel{
+				UI:        p.UI,
+				Tunneler:  tunneler,
+				RemoteApp: remoteApp,
+				Targets:   targets,
+				Help:      help,
+				Config:    localConfig,
+				Exit:      p.Exit,
 			},
 		},
 		Version: p.Version,
@@ -190,10 +271,11 @@ func (p *Plugin) GetMetadata() cfplugin.PluginMetadata {
 }
 
 func (p *Plugin) Help(name string) {
-	p.UI.Output("Usage: %s", name)
+	p.UI.Output("Usage: %s [<command> <name> [<options>]]", name)
 	p.UI.Output("Running this binary directly will automatically install the CF Local cf CLI plugin.")
 	p.UI.Output("You must have the latest version of the cf CLI and Docker installed to use CF Local.")
 	p.UI.Output("After installing, run: cf local help")
+	p.UI.Output("Commands may also be run without the cf CLI, for example: %s stage <name>", name)
 }
 
 func (p *Plugin) Install() error {
@@ -254,3 +336,11 @@ func boolEnv(k string) (v, ok bool) {
 	}
 	return false, false
 }
+
+func durationEnv(k string) (v time.Duration, ok bool) {
+	v, err := time.ParseDuration(strings.TrimSpace(os.Getenv(k)))
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/cflocal/cf/cmd"
+	"code.cloudfoundry.org/cflocal/cfclient"
+	"code.cloudfoundry.org/cflocal/config"
+	"code.cloudfoundry.org/cflocal/remote"
+)
+
+// Targets creates remote apps for the named CF targets in local.yml. Each
+// target is logged in to independently, and its tokens are cached separately
+// from the cf CLI configuration.
+type Targets struct {
+	Config *config.Config
+	UI     UI
+	Jobs   remote.JobConfig
+	Exit   <-chan struct{}
+}
+
+func (t *Targets) RemoteApp(name string) (cmd.RemoteApp, error) {
+	options, err := t.Config.LoadOptions()
+	if err != nil {
+		return nil, err
+	}
+	target, ok := options.Targets[name]
+	if !ok {
+		return nil, fmt.Errorf("target '%s' not found in local.yml", name)
+	}
+	tokenPath, err := cfclient.TokenPath(name)
+	if err != nil {
+		return nil, err
+	}
+	connection, err := cfclient.NewTargetConnection(target.API, target.SkipSSLValidation, tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	if creds := target.Credentials; creds != nil {
+		connection.Credentials = &cfclient.Credentials{
+			ClientID:     creds.ClientID,
+			ClientSecret: os.Getenv(creds.ClientSecretEnv),
+			RefreshToken: os.Getenv(creds.RefreshTokenEnv),
+		}
+		if connection.Config.RefreshToken == "" {
+			connection.Config.RefreshToken = connection.Credentials.RefreshToken
+		}
+	}
+	if err := connection.Target(target.Org, target.Space); err != nil {
+		return nil, fmt.Errorf("target '%s': %s", name, err)
+	}
+	return &remote.App{
+		CLI:      connection,
+		UI:       t.UI,
+		HTTP:     connection.HTTP,
+		Retries:  remote.DefaultRetries,
+		Jobs:     t.Jobs,
+		Exit:     t.Exit,
+		LogCache: os.Getenv("CFL_LOG_CACHE"),
+	}, nil
+}
@@ -5,11 +5,20 @@ const Usage = ShortUsage + "\n" + LongUsage
 const ShortUsage = `
    cf local stage   <name> [ (-b <name> | -b <URL> | -b <zip>)... -e ]
                            [ (-p <dir> | -p <zip>) (-s <app> | -f <app>) ]
+                           [ --target <target> ]
    cf local run     <name> [ (-i <ip>) (-p <port>) (-s <app>) (-f <app>) ]
                            [ (-d <dir> [-w] | (-d <dir>) [-t]) ]
+                           [ --remote-logs --target <target> ]
    cf local export  <name> [ (-r <ref>) ]
-   cf local pull    <name>
-   cf local push    <name> [-e -k]
+   cf local pull    <name> [--target <target>]
+   cf local logs    <name> [(--local [--follow]) | --target <target>]
+   cf local push    <name> [(-e | --env-merge) -y --dry-run]
+                           [(-k | --strategy <strategy>)]
+                           [--target <target>]
+   cf local tunnel  <name> [ (-i <ip>) --target <target> ]
+   cf local services pull <name> [ (-n <snapshot>) (--expires <duration>) ]
+                                 [ --target <target> ]
+   cf local services ls
    cf local help
    cf local version`
 
@@ -19,6 +28,9 @@ STAGE OPTIONS:
                      the environment variables and service bindings specified
                      in local.yml.
                      Droplet filename: <name>.droplet
+                     Files matching the patterns in the app's .cfignore file
+                     and under ignore in local.yml are not staged, as with
+                     cf push.
 
    -b <name>      Use one or more official CF buildpacks (specified by name).
                      Default: (uses detection)
@@ -38,15 +50,38 @@ STAGE OPTIONS:
                      Default: current working directory
    -s <app>       Use the service bindings from the specified remote CF app
                      instead of the service bindings in local.yml.
+                     Apps may be specified as <name>, <org>/<space>/<name>,
+                     or <guid>.
+                     Under remote_services in local.yml, allow limits the
+                     imported service instances, overrides replaces
+                     credential fields (by JSON path) with local values, and
+                     redact adds credential fields to hide from output.
+                     Passwords, secrets, tokens, and keys are always hidden
+                     from container output and local log files.
+                     Use -s @<snapshot> to use the service bindings saved by
+                     cf local services pull, without connecting to CF.
                      Default: (uses local.yml)
    -f <app>       Same as -s, but re-writes the service bindings to match
                      what they would be if they were tunneled through the app
                      with: cf local run <name> -f <app>
                      Default: (uses local.yml)
+   --target <target>
+                  Select the app provided by -s or -f from the named target
+                     in local.yml instead of the cf CLI target.
+                     Default: (uses cf CLI target)
 
 RUN OPTIONS:
    run <name>     Run a droplet with the configuration specified in local.yml.
                      Droplet filename: <name>.droplet
+                     HTTP services listed under stubs in local.yml are
+                     replaced with local stubs. In record mode, requests are
+                     proxied to the service URL, through the tunnel when used
+                     with -f, and the responses are saved in .cflocal/stubs
+                     without cookies or authorization headers.
+                     In replay mode, the saved responses are served without
+                     connecting to the service. The url field is the JSON
+                     path of the service URL in the credentials.
+                     Default mode: replay, Default url: $.url
 
    -i <ip>        Listen on the specified interface IP
                      Default: localhost
@@ -56,19 +91,53 @@ RUN OPTIONS:
                      The app directory from the droplet is ignored.
                      Default: (not mounted)
    -w             When used with -d, restart the app when the contents of the
-                     specified directory are changed.
+                     specified directory are changed. Changes to files that
+                     match the patterns in the directory's .cfignore file or
+                     under ignore in local.yml are ignored.
                      Default: false, Invalid: with -t, without -d
    -t             Start a shell (Bash) with the same environment as the app.
                      Default: false, Invalid: with -w
    -s <app>       Use the service bindings from the specified remote CF app
                      instead of the service bindings in local.yml.
+                     Apps may be specified as <name>, <org>/<space>/<name>,
+                     or <guid>.
+                     Under remote_services in local.yml, allow limits the
+                     imported service instances, overrides replaces
+                     credential fields (by JSON path) with local values, and
+                     redact adds credential fields to hide from output.
+                     Passwords, secrets, tokens, and keys are always hidden
+                     from container output and local log files.
+                     Use -s @<snapshot> to use the service bindings saved by
+                     cf local services pull, without connecting to CF.
                      Default: (uses local.yml or app provided by -f)
    -f <app>       Tunnel service connections through the specified remote CF
                      app. This re-writes the service bindings in the container
                      environment in order to use the tunnel. The service
                      bindings from the specified app will be used if -s is not
                      also passed.
+                     The app may be followed by /<instance> to tunnel through
+                     that instance, or by /any to tunnel through any running
+                     instance, for example: -f <name>/any
+                     Default instance: 0
+                     Lost connections to the tunnel are reported while the
+                     app runs.
+                     Addresses are found in hostname, host, port, uri, jdbcUrl,
+                     uris, hosts, brokers, and bootstrap_servers credentials
+                     at any depth, and each address is tunneled separately.
+                     Services listed under forwarding in local.yml are
+                     tunneled using the JSON paths of their host, port, and
+                     address fields, and any explicit from/to addresses.
+                     Each address is assigned an unused local port once, and
+                     the port is saved under forwarded_ports in local.yml,
+                     where it may be changed.
                      Default: (uses local.yml)
+   --remote-logs  Stream the logs of the remote app provided by -f or -s
+                     alongside the output of the local app.
+                     Default: false, Invalid: without -s or -f
+   --target <target>
+                  Select the app provided by -s or -f from the named target
+                     in local.yml instead of the cf CLI target.
+                     Default: (uses cf CLI target)
 
 EXPORT OPTIONS:
    export <name>  Export a standalone Docker image using the specified droplet
@@ -78,27 +147,180 @@ EXPORT OPTIONS:
    -r <ref>       Tag the exported image with the provided reference.
                      Default: none
 
+LOGS OPTIONS:
+   logs <name>    Stream the logs of the named remote CF app from Log Cache
+                     until interrupted.
+                     The app may be specified as <name>, <org>/<space>/<name>,
+                     or <guid>.
+
+   --target <target>
+                  Stream logs from the named target in local.yml instead of
+                     the cf CLI target.
+                     Default: (uses cf CLI target)
+
+   --local        Print the most recent logs of the named local app instead.
+                     The output of each stage and run is recorded to
+                     .cflocal/logs/<name>/<timestamp>.log, and the 10 most
+                     recent log files are kept for each app.
+
+   --follow       Continue printing new local logs as they are recorded,
+                     including the logs of later stages and runs, until
+                     interrupted. Only valid with --local.
+
 PULL OPTIONS:
    pull <name>    Download the droplet, environment variables, environment
                      variable groups, and start command of the named remote
                      CF app. The local.yml file is updated with the downloaded
                      configuration.
+                     The app may be specified as <name>, <org>/<space>/<name>,
+                     or <guid>.
                      Droplet filename: <name>.droplet
+                     Interrupted downloads are kept as <name>.droplet.partial
+                     and resumed by the next pull, unless the droplet has
+                     changed since. The droplet is verified against the
+                     checksum reported by CF, when available.
+                     The stack of the droplet is saved under droplet_stacks
+                     in local.yml, until the app is staged locally.
+
+   --target <target>
+                  Pull from the named target in local.yml instead of the
+                     cf CLI target.
+                     Default: (uses cf CLI target)
 
 PUSH OPTIONS:
    push <name>    Push a droplet to a remote CF app and restart the app.
+                     The app may be specified as <name>, <org>/<space>/<name>,
+                     or <guid>.
                      Droplet filename: <name>.droplet
 
    -e             Additionally replace the remote app environment variables
                      with the environment variables from local.yml. This does
-                     not read or replace environment variable groups.
+                     not read or replace environment variable groups. The
+                     added, changed, and removed variables are shown (with
+                     their values redacted) and must be confirmed.
+                     Default: false
+   --env-merge    Like -e, but only add or change remote app environment
+                     variables. Remote variables missing from local.yml are
+                     kept.
+                     Default: false
+   -y             Push without confirmation. Confirmation is otherwise
+                     required for environment variable changes, for apps that
+                     use a different stack than the droplet (cflinuxfs3 for
+                     staged droplets), and for apps in spaces that match a
+                     protected_spaces pattern (<org>/<space>) in local.yml.
+                     Default: false
+   --dry-run      Show the target app, the droplet size and SHA-256 hash,
+                     whether the remote app's stack matches the droplet, and
+                     any environment variable changes, without pushing.
                      Default: false
    -k             Do not restart the application after pushing the droplet.
                      The current droplet will continue to run until the next
                      restart.
                      Default: false
+   --strategy <strategy>
+                  Restart the app without downtime. With "rolling", the
+                     app instances are replaced one at a time using a CF
+                     deployment. With "blue-green", the app is renamed to
+                     <name>-venerable, a copy of it is started with the new
+                     droplet, and the routes are moved to the copy once it
+                     is running. The original app is then deleted, or
+                     restored if the copy fails to start.
+                     Default: (stop and start the app)
+   --target <target>
+                  Push to the named target in local.yml instead of the
+                     cf CLI target.
+                     Default: (uses cf CLI target)
+
+TUNNEL OPTIONS:
+   tunnel <name>  Tunnel the service connections of the named remote CF app
+                     to listeners on the host until interrupted, and show the
+                     local address of each service. Services are tunneled as
+                     with: cf local run <name> -f <app>
+                     The app may be specified as <name>, <org>/<space>/<name>,
+                     or <guid>, followed by /<instance> or /any to select the
+                     app instance to tunnel through, as with -f.
+                     If the connection is lost, it is reopened with a new SSH
+                     code, through another running instance when /any is
+                     used.
+
+   -i <ip>        Listen on the specified interface IP
+                     Default: localhost
+   --target <target>
+                  Tunnel through the named target in local.yml instead of
+                     the cf CLI target.
+                     Default: (uses cf CLI target)
+
+SERVICES OPTIONS:
+   services pull <name>
+                  Save the service bindings of the named remote CF app to an
+                     encrypted snapshot in .cflocal/services, for use with
+                     -s @<snapshot>. The allow list under remote_services in
+                     local.yml limits the saved service instances.
+                     The passphrase is read from CFL_SERVICES_PASSPHRASE,
+                     from the output of CFL_SERVICES_PASSPHRASE_COMMAND, or
+                     from the terminal, where it must be entered twice. To
+                     keep the passphrase in an OS keyring or password
+                     agent, set CFL_SERVICES_PASSPHRASE_COMMAND.
+   services ls    List the snapshots, their apps, and when they expire.
+                     Expired snapshots are not used.
+
+   -n <snapshot>  Name the snapshot.
+                     Default: (app name)
+   --expires <duration>
+                  Expire the snapshot after the specified duration.
+                     Default: 168h
+   --target <target>
+                  Select the app from the named target in local.yml instead
+                     of the cf CLI target.
+                     Default: (uses cf CLI target)
+
+GLOBAL OPTIONS:
+   --json         Output newline-delimited JSON events instead of text. Each
+                     event has a "type" of output, warning, error, progress,
+                     log, prompt, or result. Result events include values
+                     such as the droplet path, image ID, and port.
+                     Default: false
 
 ENVIRONMENT:
+   CF_HOME        Directory containing the cf CLI configuration, used when
+                     the cflocal binary is run directly without the cf CLI.
+                     Default: (home directory)
+   CFL_API        When running without the cf CLI, log in to this CF API
+                     instead of the API in the cf CLI configuration.
+   CFL_CLIENT_ID  When running without the cf CLI, log in with this UAA
+                     client (and CFL_CLIENT_SECRET) instead of the cf CLI
+                     access token. Expired tokens are renewed automatically.
+   CFL_REFRESH_TOKEN
+                  When running without the cf CLI, log in using this UAA
+                     refresh token instead of the cf CLI access token.
+   CFL_ORG        When running without the cf CLI, resolve app names in this
+   CFL_SPACE         org and space instead of the targeted org and space.
+   CFL_LOG_CACHE  Log Cache URL used to stream remote app logs.
+                     Default: (log_cache link from the CF API root)
+   CFL_JOB_POLL_INTERVAL
+                  Initial interval between polls of asynchronous CF jobs,
+                     such as droplet uploads. The interval doubles after
+                     each poll, up to 10s.
+                     Default: 500ms
+   CFL_JOB_TIMEOUT
+                  Maximum time to wait for an asynchronous CF job.
+                     Default: 10m
+   CFL_SERVICES_PASSPHRASE
+                  Passphrase used to encrypt and decrypt services snapshots.
+   CFL_SERVICES_PASSPHRASE_COMMAND
+                  Command that outputs the passphrase for services
+                     snapshots, such as a command that reads it from the OS
+                     keyring or a password agent, e.g.
+                     'security find-generic-password -s cflocal -w' (macOS)
+                     or 'secret-tool lookup service cflocal' (Linux).
+                     Used when CFL_SERVICES_PASSPHRASE is not set.
+                     Default: (prompt for the passphrase)
+   CFL_STUB_HOST  Hostname or IP that containers use to reach HTTP service
+                     stubs on the host.
+                     Default: (docker0 bridge IP on Linux,
+                     host.docker.internal otherwise)
+   CFL_STUB_IP    Interface IP that HTTP service stubs listen on.
+                     Default: (docker0 bridge IP on Linux, 127.0.0.1 otherwise)
    CFL_USE_PROXY  Always use or never use the environment's proxy settings.
                      Default: (use only when DOCKER_HOST is not set)
    DOCKER_HOST    Docker daemon address
@@ -122,4 +344,46 @@ applications:
     SOME_VAR: "some value"
   services:
     (( VCAP_SERVICES object in YAML ))
+targets:
+  prod-eu:
+    api: https://api.eu.example.com
+    org: some-org
+    space: some-space
+    skip_ssl_validation: false
+    credentials:
+      client_id: some-client
+      client_secret_env: PROD_EU_CLIENT_SECRET
+      refresh_token_env: PROD_EU_REFRESH_TOKEN
+protected_spaces:
+- "*/production"
+forwarding:
+  some-user-provided-service:
+    host: $.db.server
+    port: $.db.tcp_port
+    addresses:
+    - $.replicas
+    forwards:
+    - from: legacy-db.example.com:6000
+      to: 10.0.16.4:6000
+forwarded_ports:
+  some-user-provided-service: 40000
+  some-user-provided-service#1: 40001
+droplet_stacks:
+  some-app: cflinuxfs3
+remote_services:
+  allow:
+  - some-user-provided-service
+  - some-database
+  overrides:
+    some-database:
+      $.password: some-local-password
+  redact:
+  - license
+stubs:
+  some-http-api:
+    mode: record
+    url: $.url
+ignore:
+- node_modules
+- "*.log"
 `
@@ -9,20 +9,39 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/cflocal/cfplugin"
 )
 
 type App struct {
-	CLI  cfplugin.CliConnection
-	UI   UI
-	HTTP *http.Client
+	CLI        cfplugin.CliConnection
+	UI         UI
+	HTTP       *http.Client
+	Retries    int // zero disables retries
+	RetryDelay time.Duration
+	Jobs       JobConfig
+	Exit       <-chan struct{}
+	LogCache   string
 }
 
+const (
+	startTimeout      = 5 * time.Minute
+	startPollInterval = time.Second
+)
+
 type UI interface {
 	Warn(format string, a ...interface{})
 }
 
+type AppDetails struct {
+	Org   string
+	Space string
+	Name  string
+	Stack string
+}
+
 type AppEnv struct {
 	Staging map[string]string `json:"staging_env_json"`
 	Running map[string]string `json:"running_env_json"`
@@ -43,6 +62,46 @@ func (a *App) Command(name string) (string, error) {
 	return app.Entity.Command, nil
 }
 
+func (a *App) Details(name string) (*AppDetails, error) {
+	appJSON, _, err := a.get(name, "")
+	if err != nil {
+		return nil, err
+	}
+	defer appJSON.Close()
+	var app struct {
+		Entity struct {
+			Name      string `json:"name"`
+			SpaceGUID string `json:"space_guid"`
+			StackGUID string `json:"stack_guid"`
+		} `json:"entity"`
+	}
+	if err := json.NewDecoder(appJSON).Decode(&app); err != nil {
+		return nil, err
+	}
+
+	var space, org, stack struct {
+		Entity struct {
+			Name             string `json:"name"`
+			OrganizationGUID string `json:"organization_guid"`
+		} `json:"entity"`
+	}
+	if err := a.getEntity("/v2/spaces/"+app.Entity.SpaceGUID, &space); err != nil {
+		return nil, err
+	}
+	if err := a.getEntity("/v2/organizations/"+space.Entity.OrganizationGUID, &org); err != nil {
+		return nil, err
+	}
+	if err := a.getEntity("/v2/stacks/"+app.Entity.StackGUID, &stack); err != nil {
+		return nil, err
+	}
+	return &AppDetails{
+		Org:   org.Entity.Name,
+		Space: space.Entity.Name,
+		Name:  app.Entity.Name,
+		Stack: stack.Entity.Name,
+	}, nil
+}
+
 func (a *App) Env(name string) (*AppEnv, error) {
 	appEnvJSON, _, err := a.get(name, "/env")
 	if err != nil {
@@ -67,8 +126,82 @@ func (a *App) SetEnv(name string, env map[string]string) error {
 }
 
 func (a *App) Restart(name string) error {
-	_, err := a.CLI.CliCommand("restart", name)
-	return err
+	if err := a.setState(name, "STOPPED"); err != nil {
+		return err
+	}
+	if err := a.setState(name, "STARTED"); err != nil {
+		return err
+	}
+	return a.waitForStart(name)
+}
+
+func (a *App) setState(name, state string) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(struct {
+		State string `json:"state"`
+	}{state}); err != nil {
+		return err
+	}
+	return a.put(name, "", body, "application/json", int64(body.Len()))
+}
+
+func (a *App) waitForStart(name string) error {
+	timeout := time.After(startTimeout)
+	for {
+		response, err := a.doAppRequest(name, "GET", "/instances", nil, "", 0, http.StatusOK, http.StatusBadRequest)
+		if err != nil {
+			return err
+		}
+		var instances map[string]struct {
+			State string `json:"state"`
+		}
+		if response.StatusCode == http.StatusBadRequest {
+			err = checkInstancesError(response.Body)
+		} else {
+			err = json.NewDecoder(response.Body).Decode(&instances)
+		}
+		response.Body.Close()
+		if err != nil {
+			return err
+		}
+		crashed := len(instances) > 0
+		for _, instance := range instances {
+			if instance.State == "RUNNING" {
+				return nil
+			}
+			if instance.State != "CRASHED" {
+				crashed = false
+			}
+		}
+		if crashed {
+			return fmt.Errorf("app %s crashed", name)
+		}
+		select {
+		case <-a.Exit:
+			return ErrCancelled
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for app %s to start", name)
+		case <-time.After(startPollInterval):
+		}
+	}
+}
+
+// checkInstancesError returns nil if the Cloud Controller rejected a
+// request for an app's instances because the app is still staging or its
+// instances are not yet available.
+func checkInstancesError(body io.Reader) error {
+	var details struct {
+		Description string `json:"description"`
+		ErrorCode   string `json:"error_code"`
+	}
+	if err := json.NewDecoder(body).Decode(&details); err != nil {
+		return err
+	}
+	switch details.ErrorCode {
+	case "CF-InstancesError", "CF-StagingInProgress", "CF-NotStaged":
+		return nil
+	}
+	return fmt.Errorf("failed to get instances: %s (%s)", details.Description, details.ErrorCode)
 }
 
 func (a *App) get(name, appEndpoint string) (body io.ReadCloser, size int64, err error) {
@@ -79,6 +212,15 @@ func (a *App) get(name, appEndpoint string) (body io.ReadCloser, size int64, err
 	return response.Body, response.ContentLength, nil
 }
 
+func (a *App) getEntity(endpoint string, entity interface{}) error {
+	response, err := a.doRequest("GET", endpoint, nil, "", 0, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(entity)
+}
+
 func (a *App) put(name, appEndpoint string, body io.Reader, contentType string, contentLength int64) error {
 	response, err := a.doAppRequest(name, "PUT", appEndpoint, body, contentType, contentLength, http.StatusCreated)
 	if err != nil {
@@ -87,33 +229,49 @@ func (a *App) put(name, appEndpoint string, body io.Reader, contentType string,
 	return response.Body.Close()
 }
 
-func (a *App) doAppRequest(name, method, appEndpoint string, body io.Reader, contentType string, contentLength int64, desiredStatus int) (*http.Response, error) {
+func (a *App) doAppRequest(name, method, appEndpoint string, body io.Reader, contentType string, contentLength int64, desiredStatuses ...int) (*http.Response, error) {
 	if err := a.checkAuth(); err != nil {
 		return nil, err
 	}
-	appModel, err := a.CLI.GetApp(name)
+	guid, err := a.appGUID(name)
 	if err != nil {
 		return nil, err
 	}
-	endpoint := fmt.Sprintf("/v2/apps/%s", path.Join(appModel.Guid, appEndpoint))
-	response, err := a.doRequest(method, endpoint, body, contentType, contentLength, desiredStatus)
+	endpoint := fmt.Sprintf("/v2/apps/%s", path.Join(guid, appEndpoint))
+	response, err := a.doRequest(method, endpoint, body, contentType, contentLength, desiredStatuses...)
 	if err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
-func (a *App) doRequest(method, endpoint string, body io.Reader, contentType string, contentLength int64, desiredStatus int) (*http.Response, error) {
+func (a *App) doRequest(method, endpoint string, body io.Reader, contentType string, contentLength int64, desiredStatuses ...int) (*http.Response, error) {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return a.send(method, endpoint, body, header, contentLength, desiredStatuses...)
+}
+
+func (a *App) send(method, endpoint string, body io.Reader, header http.Header, contentLength int64, desiredStatuses ...int) (*http.Response, error) {
 	target, err := a.CLI.ApiEndpoint()
 	if err != nil {
 		return nil, err
 	}
+	return a.sendTo(target, method, endpoint, body, header, contentLength, desiredStatuses...)
+}
+
+func (a *App) sendTo(target, method, endpoint string, body io.Reader, header http.Header, contentLength int64, desiredStatuses ...int) (*http.Response, error) {
 	targetURL, err := url.Parse(target)
 	if err != nil {
 		return nil, err
 	}
+	if query := strings.SplitN(endpoint, "?", 2); len(query) == 2 {
+		endpoint = query[0]
+		targetURL.RawQuery = query[1]
+	}
 	targetURL.Path = path.Join(targetURL.Path, endpoint)
-	request, err := http.NewRequest(method, targetURL.String(), body)
+	replay, err := replayable(method, body)
 	if err != nil {
 		return nil, err
 	}
@@ -121,23 +279,67 @@ func (a *App) doRequest(method, endpoint string, body io.Reader, contentType str
 	if err != nil {
 		return nil, err
 	}
-	request.Header.Set("Authorization", token)
-	if contentType != "" {
-		request.Header.Set("Content-Type", contentType)
-	}
-	if contentLength > 0 {
-		request.ContentLength = contentLength
-	}
 
-	response, err := a.HTTP.Do(request)
-	if err != nil {
-		return nil, err
-	}
-	if response.StatusCode != desiredStatus {
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		if replay != nil {
+			body = replay()
+		}
+		request, err := http.NewRequest(method, targetURL.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			request.Header[k] = v
+		}
+		request.Header.Set("Authorization", token)
+		if contentLength > 0 {
+			request.ContentLength = contentLength
+		}
+
+		canRetry := replay != nil && attempt < a.Retries
+		response, err := a.HTTP.Do(request)
+		if err != nil {
+			if !canRetry {
+				return nil, err
+			}
+			if err := a.backoff(attempt); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		switch {
+		case hasStatus(response, desiredStatuses):
+			return response, nil
+		case response.StatusCode == http.StatusUnauthorized:
+			response.Body.Close()
+			if replay == nil || refreshed {
+				return nil, &SessionExpiredError{}
+			}
+			if token, err = a.refreshToken(); err != nil {
+				return nil, &SessionExpiredError{Reason: err}
+			}
+			refreshed = true
+			continue
+		case response.StatusCode >= 500 && canRetry:
+			response.Body.Close()
+			if err := a.backoff(attempt); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		response.Body.Close()
 		return nil, fmt.Errorf("unexpected '%s' from: %s %s", response.Status, method, targetURL.String())
 	}
-	return response, nil
+}
+
+func hasStatus(response *http.Response, statuses []int) bool {
+	for _, status := range statuses {
+		if response.StatusCode == status {
+			return true
+		}
+	}
+	return false
 }
 
 func (a *App) checkAuth() error {
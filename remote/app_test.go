@@ -1,12 +1,17 @@
 package remote_test
 
 import (
+	"errors"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"code.cloudfoundry.org/cflocal/cfplugin/models"
 	"code.cloudfoundry.org/cflocal/mocks"
 	. "code.cloudfoundry.org/cflocal/remote"
 	"code.cloudfoundry.org/cflocal/testutil"
@@ -47,6 +52,199 @@ var _ = Describe("App", func() {
 		})
 	})
 
+	Describe("#Details", func() {
+		It("should return the app's org, space, name, and stack", func() {
+			_, appCalls := server.HandleApp("some-name", http.StatusOK, `{
+				"entity": {"name": "some-name", "space_guid": "some-space-guid", "stack_guid": "some-stack-guid"}
+			}`)
+			spaceReq, spaceCalls := server.Handle(true, http.StatusOK, `{
+				"entity": {"name": "some-space", "organization_guid": "some-org-guid"}
+			}`)
+			orgReq, orgCalls := server.Handle(true, http.StatusOK, `{"entity": {"name": "some-org"}}`)
+			stackReq, stackCalls := server.Handle(true, http.StatusOK, `{"entity": {"name": "some-stack"}}`)
+			appCalls.Before(spaceCalls.Before(orgCalls.Before(stackCalls)))
+
+			Expect(app.Details("some-name")).To(Equal(&AppDetails{
+				Org:   "some-org",
+				Space: "some-space",
+				Name:  "some-name",
+				Stack: "some-stack",
+			}))
+			Expect(spaceReq.Path).To(Equal("/v2/spaces/some-space-guid"))
+			Expect(orgReq.Path).To(Equal("/v2/organizations/some-org-guid"))
+			Expect(stackReq.Path).To(Equal("/v2/stacks/some-stack-guid"))
+		})
+	})
+
+	Describe("app references", func() {
+		It("should resolve apps in other orgs and spaces", func() {
+			var queries []string
+			cc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				queries = append(queries, r.URL.Path+" "+strings.Join(r.URL.Query()["q"], ","))
+				switch r.URL.Path {
+				case "/v2/organizations":
+					w.Write([]byte(`{"resources": [{"metadata": {"guid": "some-org-guid"}}]}`))
+				case "/v2/spaces":
+					w.Write([]byte(`{"resources": [{"metadata": {"guid": "some-space-guid"}}]}`))
+				case "/v2/apps":
+					w.Write([]byte(`{"resources": [{"metadata": {"guid": "some-app-guid"}}]}`))
+				case "/v2/apps/some-app-guid":
+					w.Write([]byte(`{"entity": {"command": "some-command"}}`))
+				}
+			}))
+			defer cc.Close()
+			mockCLI.EXPECT().IsLoggedIn().Return(true, nil)
+			mockCLI.EXPECT().ApiEndpoint().Return(cc.URL, nil).Times(4)
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil).Times(4)
+
+			Expect(app.Command("some-org/some-space/some-name")).To(Equal("some-command"))
+			Expect(queries).To(Equal([]string{
+				"/v2/organizations name:some-org",
+				"/v2/spaces name:some-space,organization_guid:some-org-guid",
+				"/v2/apps name:some-name,space_guid:some-space-guid",
+				"/v2/apps/some-app-guid ",
+			}))
+		})
+
+		It("should use app GUIDs directly", func() {
+			req, calls := server.Handle(true, http.StatusOK, `{"entity": {"command": "some-command"}}`)
+			calls.AfterCall(mockCLI.EXPECT().IsLoggedIn().Return(true, nil))
+
+			Expect(app.Command("4b8a6e53-1f0c-4c4e-9b1f-6a2d0c1e2f3a")).To(Equal("some-command"))
+			Expect(req.Path).To(Equal("/v2/apps/4b8a6e53-1f0c-4c4e-9b1f-6a2d0c1e2f3a"))
+		})
+
+		It("should return an error for invalid references", func() {
+			mockCLI.EXPECT().IsLoggedIn().Return(true, nil)
+			_, err := app.Command("some-space/some-name")
+			Expect(err).To(MatchError("invalid app reference: some-space/some-name"))
+		})
+	})
+
+	Describe("request failures", func() {
+		var (
+			statuses []int
+			tokens   []string
+			cc       *httptest.Server
+		)
+
+		BeforeEach(func() {
+			tokens = nil
+			cc = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tokens = append(tokens, r.Header.Get("Authorization"))
+				status := statuses[0]
+				statuses = statuses[1:]
+				w.WriteHeader(status)
+				w.Write([]byte(`{"entity": {"command": "some-command"}}`))
+			}))
+			app.Retries = DefaultRetries
+			app.RetryDelay = time.Millisecond
+			mockCLI.EXPECT().IsLoggedIn().Return(true, nil)
+			mockCLI.EXPECT().GetApp("some-name").Return(plugin_models.GetAppModel{Guid: "some-app-guid"}, nil)
+			mockCLI.EXPECT().ApiEndpoint().Return(cc.URL, nil)
+		})
+
+		AfterEach(func() {
+			cc.Close()
+		})
+
+		It("should retry idempotent requests after server errors", func() {
+			statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil)
+
+			Expect(app.Command("some-name")).To(Equal("some-command"))
+			Expect(tokens).To(Equal([]string{"some-token", "some-token", "some-token"}))
+		})
+
+		It("should stop retrying after the configured number of retries", func() {
+			app.Retries = 1
+			statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil)
+
+			_, err := app.Command("some-name")
+			Expect(err).To(MatchError(ContainSubstring("unexpected '503 Service Unavailable'")))
+		})
+
+		It("should not retry when retries are disabled", func() {
+			app.Retries = 0
+			statuses = []int{http.StatusBadGateway, http.StatusOK}
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil)
+
+			_, err := app.Command("some-name")
+			Expect(err).To(MatchError(ContainSubstring("unexpected '502 Bad Gateway'")))
+		})
+
+		It("should stop waiting to retry when the app exits", func() {
+			exit := make(chan struct{})
+			close(exit)
+			app.Exit = exit
+			app.RetryDelay = time.Hour
+			statuses = []int{http.StatusBadGateway, http.StatusOK}
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil)
+
+			_, err := app.Command("some-name")
+			Expect(err).To(Equal(ErrCancelled))
+		})
+
+		It("should renew the access token when it is rejected", func() {
+			statuses = []int{http.StatusUnauthorized, http.StatusOK}
+			gomock.InOrder(
+				mockCLI.EXPECT().AccessToken().Return("some-expired-token", nil),
+				mockCLI.EXPECT().AccessToken().Return("some-new-token", nil),
+			)
+
+			Expect(app.Command("some-name")).To(Equal("some-command"))
+			Expect(tokens).To(Equal([]string{"some-expired-token", "some-new-token"}))
+		})
+
+		It("should return an error when the access token cannot be renewed", func() {
+			statuses = []int{http.StatusUnauthorized, http.StatusUnauthorized}
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil).Times(2)
+
+			_, err := app.Command("some-name")
+			Expect(err).To(MatchError(ErrSessionExpired.Error()))
+			Expect(IsSessionExpired(err)).To(BeTrue())
+		})
+
+		It("should return an error with the reason when the access token cannot be renewed", func() {
+			statuses = []int{http.StatusUnauthorized}
+			gomock.InOrder(
+				mockCLI.EXPECT().AccessToken().Return("some-token", nil),
+				mockCLI.EXPECT().AccessToken().Return("", errors.New("some-error")),
+			)
+
+			_, err := app.Command("some-name")
+			Expect(err).To(MatchError(ErrSessionExpired.Error() + ": some-error"))
+			Expect(IsSessionExpired(err)).To(BeTrue())
+		})
+
+		It("should return a session error when a request that cannot be repeated is rejected", func() {
+			statuses = []int{http.StatusUnauthorized}
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil)
+
+			err := app.SetDroplet("some-name", strings.NewReader("some-droplet"), 12)
+			Expect(IsSessionExpired(err)).To(BeTrue())
+			Expect(tokens).To(Equal([]string{"some-token"}))
+		})
+	})
+
+	Describe("#Name", func() {
+		It("should return the name of the app in the reference", func() {
+			Expect(app.Name("some-name")).To(Equal("some-name"))
+			Expect(app.Name("some-org/some-space/some-name")).To(Equal("some-name"))
+		})
+
+		Context("when the app is referenced by GUID", func() {
+			It("should return the app's name", func() {
+				mockCLI.EXPECT().IsLoggedIn().Return(true, nil)
+				req, _ := server.Handle(true, http.StatusOK, `{"entity": {"name": "some-name"}}`)
+
+				Expect(app.Name("8b0e3a5c-1d2f-4e6a-9b7c-0d1e2f3a4b5c")).To(Equal("some-name"))
+				Expect(req.Path).To(Equal("/v2/apps/8b0e3a5c-1d2f-4e6a-9b7c-0d1e2f3a4b5c"))
+			})
+		})
+	})
+
 	Describe("#Env", func() {
 		It("should return the app's environment variables", func() {
 			req, _ := server.HandleApp("some-name", http.StatusOK, `{
@@ -88,9 +286,67 @@ var _ = Describe("App", func() {
 	})
 
 	Describe("#Restart", func() {
-		It("should restart the app", func() {
-			mockCLI.EXPECT().CliCommand("restart", "some-name").Return(nil, nil)
+		It("should stop and start the app, then wait for it to run", func() {
+			stopReq, stopCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+			startReq, startCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+			waitReq1, waitCalls1 := server.HandleApp("some-name", http.StatusOK, `{"0": {"state": "STARTING"}}`)
+			waitReq2, waitCalls2 := server.HandleApp("some-name", http.StatusOK, `{"0": {"state": "RUNNING"}}`)
+			stopCalls.Before(startCalls.Before(waitCalls1.Before(waitCalls2)))
+
 			Expect(app.Restart("some-name")).To(Succeed())
+
+			Expect(stopReq.Method).To(Equal("PUT"))
+			Expect(stopReq.Path).To(Equal("/v2/apps/some-app-guid"))
+			Expect(stopReq.Body).To(MatchJSON(`{"state": "STOPPED"}`))
+			Expect(startReq.Method).To(Equal("PUT"))
+			Expect(startReq.Path).To(Equal("/v2/apps/some-app-guid"))
+			Expect(startReq.Body).To(MatchJSON(`{"state": "STARTED"}`))
+			Expect(waitReq1.Path).To(Equal("/v2/apps/some-app-guid/instances"))
+			Expect(waitReq2.Path).To(Equal("/v2/apps/some-app-guid/instances"))
+			Expect(waitReq2.Authenticated).To(BeTrue())
+		})
+
+		Context("when the app is still staging", func() {
+			It("should keep waiting for the app to run", func() {
+				_, stopCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+				_, startCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+				_, waitCalls1 := server.HandleApp("some-name", http.StatusBadRequest, `{
+					"code": 170002,
+					"description": "App has not finished staging",
+					"error_code": "CF-StagingInProgress"
+				}`)
+				waitReq2, waitCalls2 := server.HandleApp("some-name", http.StatusOK, `{"0": {"state": "RUNNING"}}`)
+				stopCalls.Before(startCalls.Before(waitCalls1.Before(waitCalls2)))
+
+				Expect(app.Restart("some-name")).To(Succeed())
+				Expect(waitReq2.Path).To(Equal("/v2/apps/some-app-guid/instances"))
+			})
+		})
+
+		Context("when the instances cannot be retrieved", func() {
+			It("should return an error", func() {
+				_, stopCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+				_, startCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+				_, waitCalls := server.HandleApp("some-name", http.StatusBadRequest, `{
+					"description": "some description",
+					"error_code": "CF-SomeError"
+				}`)
+				stopCalls.Before(startCalls.Before(waitCalls))
+
+				Expect(app.Restart("some-name")).To(MatchError("failed to get instances: some description (CF-SomeError)"))
+			})
+		})
+
+		Context("when every instance crashes", func() {
+			It("should return an error", func() {
+				stopReq, stopCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+				_, startCalls := server.HandleApp("some-name", http.StatusCreated, "{}")
+				_, waitCalls := server.HandleApp("some-name", http.StatusOK, `{"0": {"state": "CRASHED"}}`)
+				stopCalls.Before(startCalls.Before(waitCalls))
+
+				Expect(app.Restart("some-name")).To(MatchError("app some-name crashed"))
+				Expect(stopReq.Body).To(MatchJSON(`{"state": "STOPPED"}`))
+			})
 		})
 	})
 })
@@ -0,0 +1,349 @@
+package remote
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A credentialRewriter recognizes the remote addresses in one kind of
+// credential field, so that they can be replaced with forwarded addresses.
+type credentialRewriter interface {
+	// addresses returns the addresses in the field value, in order. Hosts
+	// without a port are returned with an empty port. If the value cannot
+	// be parsed, ok is false.
+	addresses(value interface{}) (addresses []hostPort, ok bool)
+
+	// rewrite returns the field value with each address replaced by its
+	// forwarded address.
+	rewrite(value interface{}, forwarded func(hostPort) hostPort) interface{}
+}
+
+// credentialRewriters maps credential field names to the rewriters for
+// those fields. Fields are recognized at any depth in the credentials, so
+// that nested credentials like RabbitMQ's protocols.amqp are also forwarded.
+var credentialRewriters = map[string]credentialRewriter{
+	"hostname":          addressRewriter{},
+	"host":              addressRewriter{}, // Redis
+	"uri":               urlRewriter{},     // including multi-host MongoDB URIs
+	"jdbcUrl":           urlRewriter{},
+	"uris":              addressRewriter{}, // RabbitMQ
+	"hosts":             addressRewriter{}, // Elasticsearch, RabbitMQ
+	"brokers":           addressRewriter{}, // Kafka
+	"bootstrap_servers": addressRewriter{}, // Kafka
+}
+
+type hostPort struct {
+	host, port string
+}
+
+func (h hostPort) String() string {
+	return net.JoinHostPort(h.host, h.port)
+}
+
+func parseHostPort(address string) hostPort {
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		return hostPort{host, port}
+	}
+	return hostPort{host: address}
+}
+
+// urlRewriter rewrites URLs, including URLs with a comma-separated list of
+// hosts. Rewritten hosts always include a port.
+type urlRewriter struct{}
+
+func (urlRewriter) addresses(value interface{}) ([]hostPort, bool) {
+	rawURL, _ := value.(string)
+	if rawURL == "" {
+		return nil, true
+	}
+	_, hosts, _, ok := splitURL(rawURL)
+	return hosts, ok
+}
+
+func (urlRewriter) rewrite(value interface{}, forwarded func(hostPort) hostPort) interface{} {
+	start, hosts, end, _ := splitURL(value.(string))
+	var addresses []string
+	for _, host := range hosts {
+		addresses = append(addresses, forwarded(host).String())
+	}
+	return start + strings.Join(addresses, ",") + end
+}
+
+// splitURL splits a URL around the hosts in its authority. The URL may
+// have any prefix before the scheme, such as jdbc:.
+func splitURL(rawURL string) (start string, hosts []hostPort, end string, ok bool) {
+	i := strings.Index(rawURL, "://")
+	if i < 0 {
+		return "", nil, "", false
+	}
+	hostsStart := i + len("://")
+	hostsEnd := len(rawURL)
+	if j := strings.IndexAny(rawURL[hostsStart:], "/?#"); j >= 0 {
+		hostsEnd = hostsStart + j
+	}
+	if at := strings.LastIndex(rawURL[hostsStart:hostsEnd], "@"); at >= 0 {
+		hostsStart += at + 1
+	}
+	if j := strings.IndexByte(rawURL[hostsStart:hostsEnd], ';'); j >= 0 {
+		hostsEnd = hostsStart + j
+	}
+	if hostsStart == hostsEnd {
+		return "", nil, "", false
+	}
+	for _, address := range strings.Split(rawURL[hostsStart:hostsEnd], ",") {
+		hosts = append(hosts, parseHostPort(address))
+	}
+	return rawURL[:hostsStart], hosts, rawURL[hostsEnd:], true
+}
+
+// addressRewriter rewrites a host, host:port, or URL, or a list of them
+// given as an array or a comma-separated string. Rewritten hosts only
+// include a port if the original host did.
+type addressRewriter struct{}
+
+func (addressRewriter) addresses(value interface{}) (addresses []hostPort, ok bool) {
+	for _, entry := range addressEntries(value) {
+		if strings.Contains(entry, "://") {
+			_, hosts, _, ok := splitURL(entry)
+			if !ok {
+				return nil, false
+			}
+			addresses = append(addresses, hosts...)
+		} else if entry != "" {
+			addresses = append(addresses, parseHostPort(entry))
+		}
+	}
+	return addresses, true
+}
+
+func (addressRewriter) rewrite(value interface{}, forwarded func(hostPort) hostPort) interface{} {
+	rewriteEntry := func(entry string) string {
+		if strings.Contains(entry, "://") {
+			return urlRewriter{}.rewrite(entry, forwarded).(string)
+		} else if entry == "" {
+			return entry
+		}
+		address := parseHostPort(entry)
+		if address.port == "" {
+			return forwarded(address).host
+		}
+		return forwarded(address).String()
+	}
+	switch value := value.(type) {
+	case string:
+		entries := strings.Split(value, ",")
+		for i, entry := range entries {
+			entries[i] = rewriteEntry(strings.TrimSpace(entry))
+		}
+		return strings.Join(entries, ",")
+	case []interface{}:
+		entries := make([]interface{}, len(value))
+		for i, entry := range value {
+			if s, ok := entry.(string); ok {
+				entries[i] = rewriteEntry(s)
+			} else {
+				entries[i] = entry
+			}
+		}
+		return entries
+	}
+	return value
+}
+
+func addressEntries(value interface{}) (entries []string) {
+	switch value := value.(type) {
+	case string:
+		for _, entry := range strings.Split(value, ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	case []interface{}:
+		for _, entry := range value {
+			if s, ok := entry.(string); ok {
+				entries = append(entries, s)
+			}
+		}
+	}
+	return entries
+}
+
+// credentialFields are the recognized fields of one credentials map.
+type credentialFields struct {
+	creds       map[string]interface{}
+	keys        []string
+	addresses   map[string][]hostPort
+	defaultPort string
+}
+
+// newCredentialFields finds the addresses in the recognized fields of
+// creds. Hosts without a port use the port field or, if there is no port
+// field, the first port found in another address. If any host has no port,
+// ok is false.
+func newCredentialFields(creds map[string]interface{}) (fields *credentialFields, ok bool) {
+	fields = &credentialFields{
+		creds:       creds,
+		addresses:   map[string][]hostPort{},
+		defaultPort: portString(creds["port"]),
+	}
+	for _, key := range sortedKeys(creds) {
+		rewriter, found := credentialRewriters[key]
+		if !found {
+			continue
+		}
+		addresses, ok := rewriter.addresses(creds[key])
+		if !ok {
+			return nil, false
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+		fields.keys = append(fields.keys, key)
+		fields.addresses[key] = addresses
+		for _, address := range addresses {
+			if fields.defaultPort == "" && address.port != "" {
+				fields.defaultPort = address.port
+			}
+		}
+	}
+	for _, key := range fields.keys {
+		for _, address := range fields.addresses[key] {
+			if address.host == "" || fields.resolve(address).port == "" {
+				return nil, false
+			}
+		}
+	}
+	return fields, true
+}
+
+func (c *credentialFields) resolve(address hostPort) hostPort {
+	if address.port == "" {
+		address.port = c.defaultPort
+	}
+	return address
+}
+
+// rewrite replaces the addresses in the recognized fields with their
+// forwarded addresses. A port field is rewritten to match the host field,
+// or else the first address that uses the same port.
+func (c *credentialFields) rewrite(forwarded map[hostPort]hostPort) {
+	forward := func(address hostPort) hostPort {
+		return forwarded[c.resolve(address)]
+	}
+	for _, key := range c.keys {
+		c.creds[key] = credentialRewriters[key].rewrite(c.creds[key], forward)
+	}
+
+	var portAddress *hostPort
+	for _, key := range []string{"hostname", "host"} {
+		if addresses := c.addresses[key]; len(addresses) > 0 {
+			address := c.resolve(addresses[0])
+			portAddress = &address
+			break
+		}
+	}
+	if port := portString(c.creds["port"]); portAddress == nil && port != "" {
+		for _, key := range c.keys {
+			for _, address := range c.addresses[key] {
+				if address := c.resolve(address); portAddress == nil && address.port == port {
+					portAddress = &address
+				}
+			}
+		}
+	}
+	if portAddress == nil {
+		return
+	}
+	port := forwarded[*portAddress].port
+	if _, isString := c.creds["port"].(string); isString {
+		c.creds["port"] = port
+	} else if p, err := strconv.ParseFloat(port, 64); err == nil {
+		c.creds["port"] = p
+	}
+}
+
+// forwardAddresses returns the distinct remote addresses in the
+// credentials, in the order they are found. If the top-level fields cannot
+// be forwarded, no addresses are returned.
+func forwardAddresses(creds map[string]interface{}) []hostPort {
+	var addresses []hostPort
+	ok := walkCredentials(creds, func(fields *credentialFields) {
+		for _, key := range fields.keys {
+			for _, address := range fields.addresses[key] {
+				addresses = append(addresses, fields.resolve(address))
+			}
+		}
+	})
+	if !ok {
+		return nil
+	}
+	return unique(addresses)
+}
+
+// rewriteCredentials replaces each remote address in the credentials with
+// its forwarded address.
+func rewriteCredentials(creds map[string]interface{}, forwarded map[hostPort]hostPort) {
+	walkCredentials(creds, func(fields *credentialFields) {
+		fields.rewrite(forwarded)
+	})
+}
+
+// walkCredentials visits the recognized fields of creds and of every map
+// nested within creds, depth-first in key order. Nested maps that cannot be
+// forwarded are skipped, and are left unchanged. If creds itself cannot be
+// forwarded, visit is not called at all.
+func walkCredentials(creds map[string]interface{}, visit func(*credentialFields)) (ok bool) {
+	if creds == nil {
+		return false
+	}
+	top, ok := newCredentialFields(creds)
+	if !ok {
+		return false
+	}
+	all := []*credentialFields{top}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if fields, ok := newCredentialFields(value); ok {
+				all = append(all, fields)
+			}
+			for _, key := range sortedKeys(value) {
+				walk(value[key])
+			}
+		case []interface{}:
+			for _, entry := range value {
+				walk(entry)
+			}
+		}
+	}
+	for _, key := range sortedKeys(creds) {
+		walk(creds[key])
+	}
+	for _, fields := range all {
+		visit(fields)
+	}
+	return true
+}
+
+func portString(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		if _, err := strconv.ParseUint(v, 10, 16); err == nil {
+			return v
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]interface{}) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
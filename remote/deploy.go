@@ -0,0 +1,259 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// copiedSettings are the app settings preserved when an app is copied.
+var copiedSettings = []string{
+	"space_guid",
+	"stack_guid",
+	"buildpack",
+	"command",
+	"memory",
+	"disk_quota",
+	"instances",
+	"environment_json",
+	"health_check_type",
+	"health_check_http_endpoint",
+	"health_check_timeout",
+	"enable_ssh",
+	"ports",
+}
+
+type resources struct {
+	Resources []struct {
+		Metadata struct {
+			GUID string `json:"guid"`
+		} `json:"metadata"`
+		Entity struct {
+			ServiceInstanceGUID string `json:"service_instance_guid"`
+		} `json:"entity"`
+	} `json:"resources"`
+}
+
+func (a *App) GUID(name string) (string, error) {
+	if err := a.checkAuth(); err != nil {
+		return "", err
+	}
+	return a.appGUID(name)
+}
+
+func (a *App) Rename(name, newName string) error {
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(struct {
+		Name string `json:"name"`
+	}{newName}); err != nil {
+		return err
+	}
+	return a.put(name, "", body, "application/json", int64(body.Len()))
+}
+
+// Copy creates a stopped app named newName in the same space as the app,
+// with the same settings and service bindings. The new app has no droplet
+// and no routes. If the service bindings cannot be copied, the GUID of the
+// partially-configured app is returned along with the error.
+func (a *App) Copy(name, newName string) (guid string, err error) {
+	appJSON, _, err := a.get(name, "")
+	if err != nil {
+		return "", err
+	}
+	var app struct {
+		Entity map[string]interface{} `json:"entity"`
+	}
+	err = json.NewDecoder(appJSON).Decode(&app)
+	appJSON.Close()
+	if err != nil {
+		return "", err
+	}
+
+	settings := map[string]interface{}{"name": newName, "state": "STOPPED"}
+	for _, key := range copiedSettings {
+		if value, ok := app.Entity[key]; ok && value != nil {
+			settings[key] = value
+		}
+	}
+	body := &bytes.Buffer{}
+	if err := json.NewEncoder(body).Encode(settings); err != nil {
+		return "", err
+	}
+	response, err := a.doRequest("POST", "/v2/apps", body, "application/json", int64(body.Len()), http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		Metadata struct {
+			GUID string `json:"guid"`
+		} `json:"metadata"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&created)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	guid = created.Metadata.GUID
+
+	bindingsJSON, _, err := a.get(name, "/service_bindings")
+	if err != nil {
+		return guid, err
+	}
+	var bindings resources
+	err = json.NewDecoder(bindingsJSON).Decode(&bindings)
+	bindingsJSON.Close()
+	if err != nil {
+		return guid, err
+	}
+	for _, binding := range bindings.Resources {
+		body := &bytes.Buffer{}
+		if err := json.NewEncoder(body).Encode(struct {
+			ServiceInstanceGUID string `json:"service_instance_guid"`
+			AppGUID             string `json:"app_guid"`
+		}{binding.Entity.ServiceInstanceGUID, guid}); err != nil {
+			return guid, err
+		}
+		response, err := a.doRequest("POST", "/v2/service_bindings", body, "application/json", int64(body.Len()), http.StatusCreated)
+		if err != nil {
+			return guid, err
+		}
+		response.Body.Close()
+	}
+	return guid, nil
+}
+
+// MoveRoutes maps every route of the app to the target app, then unmaps the
+// routes from the app, so that the routes are never left without an app.
+func (a *App) MoveRoutes(name, targetName string) error {
+	routesJSON, _, err := a.get(name, "/routes")
+	if err != nil {
+		return err
+	}
+	var routes resources
+	err = json.NewDecoder(routesJSON).Decode(&routes)
+	routesJSON.Close()
+	if err != nil {
+		return err
+	}
+	guid, err := a.appGUID(name)
+	if err != nil {
+		return err
+	}
+	targetGUID, err := a.appGUID(targetName)
+	if err != nil {
+		return err
+	}
+	for _, route := range routes.Resources {
+		endpoint := fmt.Sprintf("/v2/routes/%s/apps/%s", route.Metadata.GUID, targetGUID)
+		response, err := a.doRequest("PUT", endpoint, nil, "", 0, http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+	}
+	for _, route := range routes.Resources {
+		endpoint := fmt.Sprintf("/v2/routes/%s/apps/%s", route.Metadata.GUID, guid)
+		response, err := a.doRequest("DELETE", endpoint, nil, "", 0, http.StatusNoContent)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+	}
+	return nil
+}
+
+// Delete deletes the app along with its service bindings and route
+// mappings. Without recursive=true, the Cloud Controller refuses to delete
+// an app that is still bound to services.
+func (a *App) Delete(name string) error {
+	if err := a.checkAuth(); err != nil {
+		return err
+	}
+	guid, err := a.appGUID(name)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("/v2/apps/%s?recursive=true", guid)
+	response, err := a.doRequest("DELETE", endpoint, nil, "", 0, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+type deployment struct {
+	GUID   string `json:"guid"`
+	State  string `json:"state"`
+	Status struct {
+		Value  string `json:"value"`
+		Reason string `json:"reason"`
+	} `json:"status"`
+}
+
+// finished reports whether the deployment is complete. Older Cloud
+// Controllers report progress using state instead of status.
+func (d *deployment) finished() (bool, error) {
+	switch {
+	case d.Status.Value == "FINALIZED" && d.Status.Reason == "DEPLOYED", d.State == "DEPLOYED":
+		return true, nil
+	case d.Status.Value == "FINALIZED":
+		return true, fmt.Errorf("deployment %s %s", d.GUID, strings.ToLower(d.Status.Reason))
+	case d.State == "CANCELED", d.State == "FAILED":
+		return true, fmt.Errorf("deployment %s %s", d.GUID, strings.ToLower(d.State))
+	}
+	return false, nil
+}
+
+// RollingRestart replaces the app's instances with instances running the
+// current droplet one at a time, using a Cloud Controller v3 deployment.
+func (a *App) RollingRestart(name string) error {
+	if err := a.checkAuth(); err != nil {
+		return err
+	}
+	guid, err := a.appGUID(name)
+	if err != nil {
+		return err
+	}
+	body := &bytes.Buffer{}
+	var request struct {
+		Strategy      string `json:"strategy"`
+		Relationships struct {
+			App struct {
+				Data struct {
+					GUID string `json:"guid"`
+				} `json:"data"`
+			} `json:"app"`
+		} `json:"relationships"`
+	}
+	request.Strategy = "rolling"
+	request.Relationships.App.Data.GUID = guid
+	if err := json.NewEncoder(body).Encode(request); err != nil {
+		return err
+	}
+	response, err := a.doRequest("POST", "/v3/deployments", body, "application/json", int64(body.Len()), http.StatusCreated)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.After(a.jobTimeout())
+	interval := a.jobPollInterval()
+	for {
+		var deployment deployment
+		if err := decodeJob(response.Body, &deployment); err != nil {
+			return err
+		}
+		if finished, err := deployment.finished(); finished {
+			return err
+		}
+		if err := a.pause(&interval, timeout, "deployment "+deployment.GUID); err != nil {
+			return err
+		}
+		endpoint := fmt.Sprintf("/v3/deployments/%s", deployment.GUID)
+		if response, err = a.doRequest("GET", endpoint, nil, "", 0, http.StatusOK); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package remote_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cflocal/mocks"
+	. "code.cloudfoundry.org/cflocal/remote"
+	"code.cloudfoundry.org/cflocal/testutil"
+)
+
+var _ = Describe("App - Deploy", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockCLI  *mocks.MockCliConnection
+		mockUI   *mocks.MockUI
+		server   *testutil.Server
+		app      *App
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockCLI = mocks.NewMockCliConnection(mockCtrl)
+		mockUI = mocks.NewMockUI()
+		server = testutil.Serve(mockCLI)
+		app = &App{CLI: mockCLI, UI: mockUI, HTTP: &http.Client{}, Jobs: JobConfig{PollInterval: time.Millisecond}}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("#Copy", func() {
+		It("should create a stopped app with the same settings and service bindings", func() {
+			_, appCalls := server.HandleApp("some-name", http.StatusOK, `{"entity": {
+				"name": "some-name",
+				"space_guid": "some-space-guid",
+				"memory": 256,
+				"instances": 2,
+				"command": null,
+				"state": "STARTED",
+				"environment_json": {"some-key": "some-value"}
+			}}`)
+			createReq, createCalls := server.Handle(true, http.StatusCreated, `{"metadata": {"guid": "some-new-guid"}}`)
+			bindingsReq, bindingsCalls := server.HandleApp("some-name", http.StatusOK, `{"resources": [
+				{"entity": {"service_instance_guid": "some-instance-guid"}}
+			]}`)
+			bindReq, bindCalls := server.Handle(true, http.StatusCreated, "{}")
+			appCalls.Before(createCalls.Before(bindingsCalls.Before(bindCalls)))
+
+			Expect(app.Copy("some-name", "some-new-name")).To(Equal("some-new-guid"))
+
+			Expect(createReq.Method).To(Equal("POST"))
+			Expect(createReq.Path).To(Equal("/v2/apps"))
+			Expect(createReq.Body).To(MatchJSON(`{
+				"name": "some-new-name",
+				"state": "STOPPED",
+				"space_guid": "some-space-guid",
+				"memory": 256,
+				"instances": 2,
+				"environment_json": {"some-key": "some-value"}
+			}`))
+			Expect(bindingsReq.Path).To(Equal("/v2/apps/some-app-guid/service_bindings"))
+			Expect(bindReq.Path).To(Equal("/v2/service_bindings"))
+			Expect(bindReq.Body).To(MatchJSON(`{"service_instance_guid": "some-instance-guid", "app_guid": "some-new-guid"}`))
+		})
+	})
+
+	Describe("#Delete", func() {
+		It("should delete the app along with its service bindings", func() {
+			deleteReq, _ := server.HandleApp("some-name", http.StatusNoContent, "")
+
+			Expect(app.Delete("some-name")).To(Succeed())
+
+			Expect(deleteReq.Method).To(Equal("DELETE"))
+			Expect(deleteReq.Path).To(Equal("/v2/apps/some-app-guid"))
+			Expect(deleteReq.Query).To(Equal("recursive=true"))
+		})
+	})
+
+	Describe("#RollingRestart", func() {
+		It("should create a rolling deployment and wait for it to finish", func() {
+			deployReq, deployCalls := server.HandleApp("some-name", http.StatusCreated, `{"guid": "some-deployment-guid", "status": {"value": "ACTIVE"}}`)
+			pollReq, pollCalls := server.Handle(true, http.StatusOK, `{"guid": "some-deployment-guid", "status": {"value": "FINALIZED", "reason": "DEPLOYED"}}`)
+			deployCalls.Before(pollCalls)
+
+			Expect(app.RollingRestart("some-name")).To(Succeed())
+
+			Expect(deployReq.Method).To(Equal("POST"))
+			Expect(deployReq.Path).To(Equal("/v3/deployments"))
+			Expect(deployReq.Body).To(MatchJSON(`{
+				"strategy": "rolling",
+				"relationships": {"app": {"data": {"guid": "some-app-guid"}}}
+			}`))
+			Expect(pollReq.Method).To(Equal("GET"))
+			Expect(pollReq.Path).To(Equal("/v3/deployments/some-deployment-guid"))
+		})
+
+		Context("when the deployment is canceled", func() {
+			It("should return an error", func() {
+				server.HandleApp("some-name", http.StatusCreated, `{"guid": "some-deployment-guid", "state": "CANCELED"}`)
+
+				Expect(app.RollingRestart("some-name")).To(MatchError("deployment some-deployment-guid canceled"))
+			})
+		})
+	})
+})
@@ -3,15 +3,123 @@ package remote
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 )
 
-func (a *App) Droplet(name string) (droplet io.ReadCloser, size int64, err error) {
-	return a.get(name, "/droplet/download")
+// DropletDownload is the body of a droplet download.
+type DropletDownload struct {
+	io.ReadCloser
+
+	// Offset is where the body starts in the droplet. It is zero if the
+	// droplet has changed since the requested ETag.
+	Offset int64
+
+	// Size is the full size of the droplet, or -1 if the size is unknown.
+	Size int64
+
+	// ETag identifies the droplet, or is empty if the blobstore does not
+	// provide one.
+	ETag string
+}
+
+// Droplet returns the app's droplet starting at the provided offset. The
+// rest of the droplet is only requested if the droplet still has the
+// provided ETag. Otherwise, the whole droplet is returned.
+func (a *App) Droplet(name string, offset int64, etag string) (*DropletDownload, error) {
+	if err := a.checkAuth(); err != nil {
+		return nil, err
+	}
+	guid, err := a.appGUID(name)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	if offset > 0 && etag != "" {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		header.Set("If-Range", etag)
+	}
+	endpoint := fmt.Sprintf("/v2/apps/%s/droplet/download", guid)
+	response, err := a.send("GET", endpoint, nil, header, 0,
+		http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable)
+	if err != nil {
+		return nil, err
+	}
+	download := &DropletDownload{
+		ReadCloser: response.Body,
+		Size:       response.ContentLength,
+		ETag:       response.Header.Get("ETag"),
+	}
+	if response.StatusCode == http.StatusOK {
+		return download, nil
+	}
+	if download.ETag != "" && download.ETag != etag {
+		// the blobstore ignored If-Range, and the droplet has changed
+		response.Body.Close()
+		return a.Droplet(name, 0, "")
+	}
+	download.Offset = offset
+	download.ETag = etag
+	if response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		response.Body.Close()
+		// a smaller droplet has replaced the droplet
+		if response.Header.Get("Content-Range") != fmt.Sprintf("bytes */%d", offset) {
+			return a.Droplet(name, 0, "")
+		}
+		// nothing is left to download when the offset is already the full size
+		download.ReadCloser = ioutil.NopCloser(&bytes.Buffer{})
+		download.Size = offset
+		return download, nil
+	}
+	download.Size = partialSize(response, offset)
+	return download, nil
+}
+
+// partialSize returns the full size of a droplet from a partial response
+// that starts at offset, or -1 if the size is unknown.
+func partialSize(response *http.Response, offset int64) int64 {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err == nil {
+		return total
+	}
+	if response.ContentLength < 0 {
+		return -1
+	}
+	return offset + response.ContentLength
+}
+
+// DropletChecksum returns the checksum of the app's current droplet, or an
+// empty checksum if the Cloud Controller does not provide one.
+func (a *App) DropletChecksum(name string) (algorithm, checksum string, err error) {
+	if err := a.checkAuth(); err != nil {
+		return "", "", err
+	}
+	guid, err := a.appGUID(name)
+	if err != nil {
+		return "", "", err
+	}
+	endpoint := fmt.Sprintf("/v3/apps/%s/droplets/current", guid)
+	response, err := a.send("GET", endpoint, nil, nil, 0, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return "", "", nil
+	}
+	var droplet struct {
+		Checksum struct {
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		} `json:"checksum"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&droplet); err != nil {
+		return "", "", err
+	}
+	return droplet.Checksum.Type, droplet.Checksum.Value, nil
 }
 
 func (a *App) SetDroplet(name string, droplet io.Reader, size int64) error {
@@ -56,44 +164,3 @@ func emptyMultipartSize(fieldname, filename string) int64 {
 	form.Close()
 	return int64(body.Len())
 }
-
-func (a *App) putJob(name, appEndpoint string, body io.Reader, contentType string, contentLength int64) error {
-	response, err := a.doAppRequest(name, "PUT", appEndpoint, body, contentType, contentLength, http.StatusCreated)
-	if err != nil {
-		return err
-	}
-	return a.waitForJob(response.Body)
-}
-
-func (a *App) waitForJob(body io.ReadCloser) error {
-	for {
-		var job struct {
-			Entity struct {
-				GUID   string `json:"guid"`
-				Status string `json:"status"`
-			} `json:"entity"`
-		}
-		if err := decodeJob(body, &job); err != nil {
-			return err
-		}
-
-		switch job.Entity.Status {
-		case "queued", "running":
-			endpoint := fmt.Sprintf("/v2/jobs/%s", job.Entity.GUID)
-			response, err := a.doRequest("GET", endpoint, nil, "", 0, http.StatusOK)
-			if err != nil {
-				return err
-			}
-			body = response.Body
-		case "finished":
-			return nil
-		default:
-			return errors.New("job failed")
-		}
-	}
-}
-
-func decodeJob(body io.ReadCloser, job interface{}) error {
-	defer body.Close()
-	return json.NewDecoder(body).Decode(job)
-}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"code.cloudfoundry.org/cflocal/cfplugin/models"
 	"code.cloudfoundry.org/cflocal/mocks"
 	. "code.cloudfoundry.org/cflocal/remote"
 	"code.cloudfoundry.org/cflocal/testutil"
@@ -28,7 +31,7 @@ var _ = Describe("App - Droplet", func() {
 		mockCLI = mocks.NewMockCliConnection(mockCtrl)
 		mockUI = mocks.NewMockUI()
 		server = testutil.Serve(mockCLI)
-		app = &App{CLI: mockCLI, UI: mockUI, HTTP: &http.Client{}}
+		app = &App{CLI: mockCLI, UI: mockUI, HTTP: &http.Client{}, Jobs: JobConfig{PollInterval: time.Millisecond}}
 	})
 
 	AfterEach(func() {
@@ -39,16 +42,180 @@ var _ = Describe("App - Droplet", func() {
 		It("should return the app's droplet", func() {
 			req, _ := server.HandleApp("some-name", http.StatusOK, "some-droplet")
 
-			droplet, size, err := app.Droplet("some-name")
+			droplet, err := app.Droplet("some-name", 0, "")
 			Expect(err).NotTo(HaveOccurred())
 			defer droplet.Close()
 
-			Expect(size).To(Equal(int64(12)))
+			Expect(droplet.Offset).To(Equal(int64(0)))
+			Expect(droplet.Size).To(Equal(int64(12)))
 			Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("some-droplet")))
 
 			Expect(req.Method).To(Equal("GET"))
 			Expect(req.Path).To(Equal("/v2/apps/some-app-guid/droplet/download"))
 			Expect(req.Authenticated).To(BeTrue())
+			Expect(req.Range).To(BeEmpty())
+		})
+
+		It("should request the whole droplet when an offset is provided without an ETag", func() {
+			req, _ := server.HandleApp("some-name", http.StatusOK, "some-droplet")
+
+			droplet, err := app.Droplet("some-name", 5, "")
+			Expect(err).NotTo(HaveOccurred())
+			defer droplet.Close()
+
+			Expect(droplet.Offset).To(Equal(int64(0)))
+			Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("some-droplet")))
+			Expect(req.Range).To(BeEmpty())
+		})
+
+		Context("when an offset and ETag are provided", func() {
+			type response struct {
+				status       int
+				etag         string
+				contentRange string
+				body         string
+			}
+
+			var (
+				responses []response
+				ranges    []string
+				ifRanges  []string
+				cc        *httptest.Server
+			)
+
+			BeforeEach(func() {
+				responses, ranges, ifRanges = nil, nil, nil
+				cc = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					ranges = append(ranges, r.Header.Get("Range"))
+					ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+					res := responses[0]
+					responses = responses[1:]
+					if res.etag != "" {
+						w.Header().Set("ETag", res.etag)
+					}
+					if res.contentRange != "" {
+						w.Header().Set("Content-Range", res.contentRange)
+					}
+					w.WriteHeader(res.status)
+					w.(http.Flusher).Flush()
+					w.Write([]byte(res.body))
+				}))
+				mockCLI.EXPECT().IsLoggedIn().Return(true, nil).AnyTimes()
+				mockCLI.EXPECT().GetApp("some-name").Return(plugin_models.GetAppModel{Guid: "some-app-guid"}, nil).AnyTimes()
+				mockCLI.EXPECT().ApiEndpoint().Return(cc.URL, nil).AnyTimes()
+				mockCLI.EXPECT().AccessToken().Return("some-token", nil).AnyTimes()
+			})
+
+			AfterEach(func() {
+				cc.Close()
+			})
+
+			It("should request the rest of the droplet if it has not changed", func() {
+				responses = []response{{http.StatusPartialContent, `"some-etag"`, "bytes 5-11/12", "droplet"}}
+
+				droplet, err := app.Droplet("some-name", 5, `"some-etag"`)
+				Expect(err).NotTo(HaveOccurred())
+				defer droplet.Close()
+
+				Expect(droplet.Offset).To(Equal(int64(5)))
+				Expect(droplet.Size).To(Equal(int64(12)))
+				Expect(droplet.ETag).To(Equal(`"some-etag"`))
+				Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("droplet")))
+				Expect(ranges).To(Equal([]string{"bytes=5-"}))
+				Expect(ifRanges).To(Equal([]string{`"some-etag"`}))
+			})
+
+			It("should return an unknown size without a content range", func() {
+				responses = []response{{http.StatusPartialContent, "", "", "droplet"}}
+
+				droplet, err := app.Droplet("some-name", 5, `"some-etag"`)
+				Expect(err).NotTo(HaveOccurred())
+				defer droplet.Close()
+
+				Expect(droplet.Offset).To(Equal(int64(5)))
+				Expect(droplet.Size).To(Equal(int64(-1)))
+				Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("droplet")))
+			})
+
+			It("should return the whole droplet if it has changed", func() {
+				responses = []response{{http.StatusOK, `"some-new-etag"`, "", "some-new-droplet"}}
+
+				droplet, err := app.Droplet("some-name", 5, `"some-etag"`)
+				Expect(err).NotTo(HaveOccurred())
+				defer droplet.Close()
+
+				Expect(droplet.Offset).To(Equal(int64(0)))
+				Expect(droplet.ETag).To(Equal(`"some-new-etag"`))
+				Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("some-new-droplet")))
+			})
+
+			It("should return the whole droplet if it has changed and If-Range is ignored", func() {
+				responses = []response{
+					{http.StatusPartialContent, `"some-new-etag"`, "bytes 5-15/16", "new-droplet"},
+					{http.StatusOK, `"some-new-etag"`, "", "some-new-droplet"},
+				}
+
+				droplet, err := app.Droplet("some-name", 5, `"some-etag"`)
+				Expect(err).NotTo(HaveOccurred())
+				defer droplet.Close()
+
+				Expect(droplet.Offset).To(Equal(int64(0)))
+				Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("some-new-droplet")))
+				Expect(ranges).To(Equal([]string{"bytes=5-", ""}))
+			})
+
+			It("should return the whole droplet if a smaller droplet has replaced it", func() {
+				responses = []response{
+					{http.StatusRequestedRangeNotSatisfiable, "", "bytes */3", ""},
+					{http.StatusOK, `"some-new-etag"`, "", "new"},
+				}
+
+				droplet, err := app.Droplet("some-name", 5, `"some-etag"`)
+				Expect(err).NotTo(HaveOccurred())
+				defer droplet.Close()
+
+				Expect(droplet.Offset).To(Equal(int64(0)))
+				Expect(droplet.ETag).To(Equal(`"some-new-etag"`))
+				Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("new")))
+				Expect(ranges).To(Equal([]string{"bytes=5-", ""}))
+			})
+
+			It("should return nothing if the droplet was already downloaded", func() {
+				responses = []response{{http.StatusRequestedRangeNotSatisfiable, "", "bytes */5", ""}}
+
+				droplet, err := app.Droplet("some-name", 5, `"some-etag"`)
+				Expect(err).NotTo(HaveOccurred())
+				defer droplet.Close()
+
+				Expect(droplet.Offset).To(Equal(int64(5)))
+				Expect(droplet.Size).To(Equal(int64(5)))
+				Expect(droplet.ETag).To(Equal(`"some-etag"`))
+				Expect(ioutil.ReadAll(droplet)).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("#DropletChecksum", func() {
+		It("should return the checksum of the app's current droplet", func() {
+			req, _ := server.HandleApp("some-name", http.StatusOK, `{"checksum": {"type": "sha256", "value": "some-checksum"}}`)
+
+			algorithm, checksum, err := app.DropletChecksum("some-name")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(algorithm).To(Equal("sha256"))
+			Expect(checksum).To(Equal("some-checksum"))
+
+			Expect(req.Method).To(Equal("GET"))
+			Expect(req.Path).To(Equal("/v3/apps/some-app-guid/droplets/current"))
+			Expect(req.Authenticated).To(BeTrue())
+		})
+
+		It("should return an empty checksum when the droplet is not found", func() {
+			server.HandleApp("some-name", http.StatusNotFound, "")
+
+			algorithm, checksum, err := app.DropletChecksum("some-name")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(algorithm).To(BeEmpty())
+			Expect(checksum).To(BeEmpty())
 		})
 	})
 
@@ -83,5 +250,43 @@ var _ = Describe("App - Droplet", func() {
 			Expect(jobReq2.Path).To(Equal("/v2/jobs/some-guid"))
 			Expect(jobReq2.Authenticated).To(BeTrue())
 		})
+
+		Context("when the upload job fails", func() {
+			It("should return the error details from the job", func() {
+				_, appCalls := server.HandleApp("some-name", http.StatusCreated, `{"entity": {"guid": "some-guid", "status": "queued"}}`)
+				_, jobCalls := server.Handle(true, http.StatusOK, `{"entity": {
+					"guid": "some-guid",
+					"status": "failed",
+					"error_details": {"code": 170001, "description": "some-description", "error_code": "CF-SomeError"}
+				}}`)
+				appCalls.Before(jobCalls)
+
+				droplet := bytes.NewBufferString("some-droplet")
+				Expect(app.SetDroplet("some-name", droplet, int64(droplet.Len()))).To(MatchError("job some-guid failed: some-description (CF-SomeError)"))
+			})
+		})
+
+		Context("when the upload job does not finish in time", func() {
+			It("should return a timeout error", func() {
+				app.Jobs = JobConfig{PollInterval: time.Hour, Timeout: 10 * time.Millisecond}
+				server.HandleApp("some-name", http.StatusCreated, `{"entity": {"guid": "some-guid", "status": "queued"}}`)
+
+				droplet := bytes.NewBufferString("some-droplet")
+				Expect(app.SetDroplet("some-name", droplet, int64(droplet.Len()))).To(MatchError("timed out waiting for job some-guid"))
+			})
+		})
+
+		Context("when the plugin exits while the upload job is running", func() {
+			It("should stop polling the job", func() {
+				exit := make(chan struct{})
+				close(exit)
+				app.Exit = exit
+				app.Jobs = JobConfig{PollInterval: time.Hour}
+				server.HandleApp("some-name", http.StatusCreated, `{"entity": {"guid": "some-guid", "status": "running"}}`)
+
+				droplet := bytes.NewBufferString("some-droplet")
+				Expect(app.SetDroplet("some-name", droplet, int64(droplet.Len()))).To(MatchError(ErrCancelled))
+			})
+		})
 	})
 })
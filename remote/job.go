@@ -0,0 +1,127 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultJobPollInterval    = 500 * time.Millisecond
+	defaultJobMaxPollInterval = 10 * time.Second
+	defaultJobTimeout         = 10 * time.Minute
+)
+
+var ErrCancelled = errors.New("cancelled")
+
+// JobConfig controls how asynchronous Cloud Controller jobs are polled.
+// The poll interval doubles after each poll, up to the max poll interval.
+type JobConfig struct {
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+	Timeout         time.Duration
+}
+
+type job struct {
+	Entity struct {
+		GUID         string `json:"guid"`
+		Status       string `json:"status"`
+		ErrorDetails *struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+			ErrorCode   string `json:"error_code"`
+		} `json:"error_details"`
+	} `json:"entity"`
+}
+
+func (j *job) err() error {
+	details := j.Entity.ErrorDetails
+	if details == nil || details.Description == "" {
+		return fmt.Errorf("job %s failed", j.Entity.GUID)
+	}
+	if details.ErrorCode == "" {
+		return fmt.Errorf("job %s failed: %s", j.Entity.GUID, details.Description)
+	}
+	return fmt.Errorf("job %s failed: %s (%s)", j.Entity.GUID, details.Description, details.ErrorCode)
+}
+
+func (a *App) putJob(name, appEndpoint string, body io.Reader, contentType string, contentLength int64) error {
+	response, err := a.doAppRequest(name, "PUT", appEndpoint, body, contentType, contentLength, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return a.waitForJob(response.Body)
+}
+
+func (a *App) waitForJob(body io.ReadCloser) error {
+	timeout := time.After(a.jobTimeout())
+	interval := a.jobPollInterval()
+	for {
+		var job job
+		if err := decodeJob(body, &job); err != nil {
+			return err
+		}
+
+		switch job.Entity.Status {
+		case "queued", "running":
+			if err := a.pause(&interval, timeout, "job "+job.Entity.GUID); err != nil {
+				return err
+			}
+			endpoint := fmt.Sprintf("/v2/jobs/%s", job.Entity.GUID)
+			response, err := a.doRequest("GET", endpoint, nil, "", 0, http.StatusOK)
+			if err != nil {
+				return err
+			}
+			body = response.Body
+		case "finished":
+			return nil
+		default:
+			return job.err()
+		}
+	}
+}
+
+// pause waits for the next poll of an asynchronous operation, and then
+// doubles the poll interval.
+func (a *App) pause(interval *time.Duration, timeout <-chan time.Time, operation string) error {
+	select {
+	case <-a.Exit:
+		return ErrCancelled
+	case <-timeout:
+		return fmt.Errorf("timed out waiting for %s", operation)
+	case <-time.After(*interval):
+	}
+	if *interval *= 2; *interval > a.jobMaxPollInterval() {
+		*interval = a.jobMaxPollInterval()
+	}
+	return nil
+}
+
+func (a *App) jobPollInterval() time.Duration {
+	if a.Jobs.PollInterval <= 0 {
+		return defaultJobPollInterval
+	}
+	return a.Jobs.PollInterval
+}
+
+func (a *App) jobMaxPollInterval() time.Duration {
+	if a.Jobs.MaxPollInterval <= 0 {
+		return defaultJobMaxPollInterval
+	}
+	return a.Jobs.MaxPollInterval
+}
+
+func (a *App) jobTimeout() time.Duration {
+	if a.Jobs.Timeout <= 0 {
+		return defaultJobTimeout
+	}
+	return a.Jobs.Timeout
+}
+
+func decodeJob(body io.ReadCloser, job interface{}) error {
+	defer body.Close()
+	return json.NewDecoder(body).Decode(job)
+}
@@ -0,0 +1,164 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const logPollInterval = time.Second
+
+type LogMessage struct {
+	Time       time.Time
+	SourceType string
+	InstanceID string
+	Message    string
+	Error      bool
+}
+
+// StreamLogs sends new log messages from the app to the returned channel
+// until stop is closed, at which point the channel is closed. Logs are read
+// from Log Cache, which is found using the links advertised by the Cloud
+// Controller unless LogCache is set.
+func (a *App) StreamLogs(name string, stop <-chan struct{}) (<-chan LogMessage, error) {
+	if err := a.checkAuth(); err != nil {
+		return nil, err
+	}
+	guid, err := a.appGUID(name)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := a.logCacheEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(chan LogMessage)
+	go func() {
+		defer close(messages)
+		start := time.Now().UnixNano()
+		// messages with the start time are read again by the next request,
+		// since a batch may end before all of them are read
+		sent := map[LogMessage]bool{}
+		failing := false
+		for {
+			batch, err := a.readLogs(endpoint, guid, start)
+			if err != nil && !failing {
+				a.UI.Warn("failed to read logs for %s: %s", name, err)
+			}
+			failing = err != nil
+			for _, message := range batch {
+				if sent[message] {
+					continue
+				}
+				select {
+				case messages <- message:
+				case <-stop:
+					return
+				}
+				if timestamp := message.Time.UnixNano(); timestamp != start {
+					start = timestamp
+					sent = map[LogMessage]bool{}
+				}
+				sent[message] = true
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(logPollInterval):
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// logCacheEndpoint returns LogCache if it is set, or the Log Cache link from
+// the root of the Cloud Controller API.
+func (a *App) logCacheEndpoint() (string, error) {
+	if a.LogCache != "" {
+		return a.LogCache, nil
+	}
+	target, err := a.CLI.ApiEndpoint()
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequest("GET", strings.TrimSuffix(target, "/")+"/", nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := a.HTTP.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected '%s' from: GET %s", response.Status, request.URL)
+	}
+	var root struct {
+		Links struct {
+			LogCache struct {
+				Href string `json:"href"`
+			} `json:"log_cache"`
+		} `json:"links"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&root); err != nil {
+		return "", err
+	}
+	if root.Links.LogCache.Href == "" {
+		return "", fmt.Errorf("unable to find Log Cache for %s: set CFL_LOG_CACHE", target)
+	}
+	return root.Links.LogCache.Href, nil
+}
+
+func (a *App) readLogs(endpoint, guid string, start int64) ([]LogMessage, error) {
+	query := url.Values{
+		"start_time":     {strconv.FormatInt(start, 10)},
+		"envelope_types": {"LOG"},
+		"limit":          {"1000"},
+	}
+	path := fmt.Sprintf("/api/v1/read/%s?%s", guid, query.Encode())
+	response, err := a.sendTo(endpoint, "GET", path, nil, nil, 0, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Envelopes struct {
+			Batch []struct {
+				Timestamp  string            `json:"timestamp"`
+				InstanceID string            `json:"instance_id"`
+				Tags       map[string]string `json:"tags"`
+				Log        *struct {
+					Payload []byte `json:"payload"`
+					Type    string `json:"type"`
+				} `json:"log"`
+			} `json:"batch"`
+		} `json:"envelopes"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	var messages []LogMessage
+	for _, envelope := range result.Envelopes.Batch {
+		if envelope.Log == nil {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(envelope.Timestamp, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, LogMessage{
+			Time:       time.Unix(0, timestamp),
+			SourceType: envelope.Tags["source_type"],
+			InstanceID: envelope.InstanceID,
+			Message:    strings.TrimRight(string(envelope.Log.Payload), "\r\n"),
+			Error:      envelope.Log.Type == "ERR",
+		})
+	}
+	return messages, nil
+}
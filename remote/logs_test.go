@@ -0,0 +1,204 @@
+package remote_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cflocal/cfplugin/models"
+	"code.cloudfoundry.org/cflocal/mocks"
+	. "code.cloudfoundry.org/cflocal/remote"
+)
+
+var _ = Describe("App - Logs", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockCLI  *mocks.MockCliConnection
+		mockUI   *mocks.MockUI
+		app      *App
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockCLI = mocks.NewMockCliConnection(mockCtrl)
+		mockUI = mocks.NewMockUI()
+		app = &App{CLI: mockCLI, UI: mockUI, HTTP: &http.Client{}}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("#StreamLogs", func() {
+		It("should stream the app's log messages from Log Cache", func() {
+			var (
+				mutex    sync.Mutex
+				requests []*http.Request
+			)
+			logCache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				requests = append(requests, r)
+				if len(requests) > 1 {
+					w.Write([]byte(`{"envelopes": {"batch": []}}`))
+					return
+				}
+				w.Write([]byte(`{"envelopes": {"batch": [
+					{
+						"timestamp": "1500000000000000000",
+						"instance_id": "0",
+						"tags": {"source_type": "APP/PROC/WEB"},
+						"log": {"payload": "c29tZS1tZXNzYWdlCg==", "type": "OUT"}
+					},
+					{"timestamp": "1500000000000000001", "gauge": {}},
+					{
+						"timestamp": "1500000000000000002",
+						"instance_id": "1",
+						"tags": {"source_type": "APP/PROC/WEB"},
+						"log": {"payload": "c29tZS1lcnJvcg==", "type": "ERR"}
+					}
+				]}}`))
+			}))
+			defer logCache.Close()
+			app.LogCache = logCache.URL
+
+			mockCLI.EXPECT().IsLoggedIn().Return(true, nil)
+			mockCLI.EXPECT().GetApp("some-name").Return(plugin_models.GetAppModel{Guid: "some-app-guid"}, nil)
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil).AnyTimes()
+
+			stop := make(chan struct{})
+			messages, err := app.StreamLogs("some-name", stop)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(messages).Should(Receive(Equal(LogMessage{
+				Time:       time.Unix(0, 1500000000000000000),
+				SourceType: "APP/PROC/WEB",
+				InstanceID: "0",
+				Message:    "some-message",
+			})))
+			Eventually(messages).Should(Receive(Equal(LogMessage{
+				Time:       time.Unix(0, 1500000000000000002),
+				SourceType: "APP/PROC/WEB",
+				InstanceID: "1",
+				Message:    "some-error",
+				Error:      true,
+			})))
+			Eventually(func() int {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return len(requests)
+			}, "3s").Should(BeNumerically(">", 1))
+			close(stop)
+			Eventually(messages).Should(BeClosed())
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			Expect(requests[0].URL.Path).To(Equal("/api/v1/read/some-app-guid"))
+			Expect(requests[0].URL.Query().Get("envelope_types")).To(Equal("LOG"))
+			Expect(requests[0].Header.Get("Authorization")).To(Equal("some-token"))
+			Expect(requests[1].URL.Query().Get("start_time")).To(Equal("1500000000000000002"))
+		})
+
+		It("should not skip or repeat messages with the same time as the end of a batch", func() {
+			var (
+				mutex      sync.Mutex
+				startTimes []string
+			)
+			batches := []string{
+				`{"timestamp": "1500000000000000000", "log": {"payload": "c29tZS1tZXNzYWdlLTE=", "type": "OUT"}},
+				{"timestamp": "1500000000000000001", "log": {"payload": "c29tZS1tZXNzYWdlLTI=", "type": "OUT"}}`,
+				`{"timestamp": "1500000000000000001", "log": {"payload": "c29tZS1tZXNzYWdlLTI=", "type": "OUT"}},
+				{"timestamp": "1500000000000000001", "log": {"payload": "c29tZS1tZXNzYWdlLTM=", "type": "OUT"}}`,
+			}
+			logCache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				startTimes = append(startTimes, r.URL.Query().Get("start_time"))
+				batch := ""
+				if len(batches) > 0 {
+					batch, batches = batches[0], batches[1:]
+				}
+				w.Write([]byte(`{"envelopes": {"batch": [` + batch + `]}}`))
+			}))
+			defer logCache.Close()
+			app.LogCache = logCache.URL
+
+			mockCLI.EXPECT().IsLoggedIn().Return(true, nil)
+			mockCLI.EXPECT().GetApp("some-name").Return(plugin_models.GetAppModel{Guid: "some-app-guid"}, nil)
+			mockCLI.EXPECT().AccessToken().Return("some-token", nil).AnyTimes()
+
+			stop := make(chan struct{})
+			messages, err := app.StreamLogs("some-name", stop)
+			Expect(err).NotTo(HaveOccurred())
+
+			var received []string
+			for i := 0; i < 3; i++ {
+				var message LogMessage
+				Eventually(messages, "3s").Should(Receive(&message))
+				received = append(received, message.Message)
+			}
+			Expect(received).To(Equal([]string{"some-message-1", "some-message-2", "some-message-3"}))
+			Consistently(messages, "1500ms").ShouldNot(Receive())
+			close(stop)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			Expect(startTimes[1]).To(Equal("1500000000000000001"))
+		})
+
+		Context("when LogCache is not set", func() {
+			var (
+				root string
+				cc   *httptest.Server
+			)
+
+			BeforeEach(func() {
+				cc = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Expect(r.URL.Path).To(Equal("/"))
+					w.Write([]byte(root))
+				}))
+				mockCLI.EXPECT().IsLoggedIn().Return(true, nil)
+				mockCLI.EXPECT().GetApp("some-name").Return(plugin_models.GetAppModel{Guid: "some-app-guid"}, nil)
+				mockCLI.EXPECT().ApiEndpoint().Return(cc.URL, nil)
+			})
+
+			AfterEach(func() {
+				cc.Close()
+			})
+
+			It("should read logs from the Log Cache link in the API root", func() {
+				logCache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Expect(r.URL.Path).To(Equal("/api/v1/read/some-app-guid"))
+					w.Write([]byte(`{"envelopes": {"batch": [{
+						"timestamp": "1500000000000000000",
+						"log": {"payload": "c29tZS1tZXNzYWdl", "type": "OUT"}
+					}]}}`))
+				}))
+				defer logCache.Close()
+				root = `{"links": {"log_cache": {"href": "` + logCache.URL + `"}}}`
+				mockCLI.EXPECT().AccessToken().Return("some-token", nil).AnyTimes()
+
+				stop := make(chan struct{})
+				defer close(stop)
+				messages, err := app.StreamLogs("some-name", stop)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(messages).Should(Receive(Equal(LogMessage{
+					Time:    time.Unix(0, 1500000000000000000),
+					Message: "some-message",
+				})))
+			})
+
+			It("should return an error when the API root has no Log Cache link", func() {
+				root = `{"links": {"self": {"href": "` + cc.URL + `"}}}`
+
+				_, err := app.StreamLogs("some-name", nil)
+				Expect(err).To(MatchError("unable to find Log Cache for " + cc.URL + ": set CFL_LOG_CACHE"))
+			})
+		})
+	})
+})
@@ -0,0 +1,76 @@
+package remote
+
+import "fmt"
+
+const firstForwardedServicePort uint = 40000
+
+// ForwardOptions configure how services are forwarded.
+type ForwardOptions struct {
+	// Rules are forwarding rules keyed by service instance name.
+	Rules map[string]*ForwardRule
+
+	// Ports are the local ports of forwarded addresses, keyed by service
+	// instance name, with #<n> appended for each address after the first.
+	// Addresses without a port are assigned the lowest unused port, and
+	// the assignment is added to Ports.
+	Ports map[string]uint
+
+	// Available reports whether a port is free to use locally. If it is
+	// nil, all ports are considered available.
+	Available func(port uint) bool
+}
+
+type portAllocator struct {
+	ports     map[string]uint
+	available func(port uint) bool
+	reserved  map[uint]bool
+	used      map[uint]string
+	warn      func(format string, a ...interface{})
+}
+
+func newPortAllocator(options *ForwardOptions, warn func(format string, a ...interface{})) *portAllocator {
+	allocator := &portAllocator{
+		ports:     map[string]uint{},
+		available: func(uint) bool { return true },
+		reserved:  map[uint]bool{},
+		used:      map[uint]string{},
+		warn:      warn,
+	}
+	if options != nil {
+		if options.Ports == nil {
+			options.Ports = map[string]uint{}
+		}
+		allocator.ports = options.Ports
+		if options.Available != nil {
+			allocator.available = options.Available
+		}
+	}
+	for _, port := range allocator.ports {
+		allocator.reserved[port] = true
+	}
+	return allocator
+}
+
+// port returns the local port for the key, assigning a new port if the key
+// does not have one. Ports that are already assigned are never reassigned,
+// so that adding a service does not change the ports of other services.
+func (p *portAllocator) port(key string) (uint, error) {
+	port, ok := p.ports[key]
+	if ok {
+		if other, used := p.used[port]; used {
+			return 0, fmt.Errorf("forwarded port %d is assigned to both %s and %s", port, other, key)
+		}
+		if !p.available(port) {
+			p.warn("forwarded port %d for %s is already in use locally", port, key)
+		}
+	} else {
+		port = firstForwardedServicePort
+		for p.reserved[port] || !p.available(port) {
+			port++
+		}
+		p.ports[key] = port
+		p.reserved[port] = true
+	}
+	p.used[port] = key
+	return port, nil
+}
@@ -0,0 +1,144 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// appGUID resolves an app reference, which may be the name of an app in the
+// targeted space, an org/space/app path, or an app GUID.
+func (a *App) appGUID(ref string) (string, error) {
+	if guidPattern.MatchString(ref) {
+		return ref, nil
+	}
+	parts := strings.Split(ref, "/")
+	switch len(parts) {
+	case 1:
+		appModel, err := a.CLI.GetApp(ref)
+		if err != nil {
+			return "", err
+		}
+		return appModel.Guid, nil
+	case 3:
+		if parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			break
+		}
+		orgGUID, err := a.findGUID("organization", "/v2/organizations", parts[0])
+		if err != nil {
+			return "", err
+		}
+		spaceGUID, err := a.findGUID("space", "/v2/spaces", parts[1], "organization_guid:"+orgGUID)
+		if err != nil {
+			return "", err
+		}
+		return a.findGUID("app", "/v2/apps", parts[2], "space_guid:"+spaceGUID)
+	}
+	return "", fmt.Errorf("invalid app reference: %s", ref)
+}
+
+func (a *App) findGUID(kind, endpoint, name string, filters ...string) (string, error) {
+	query := url.Values{"q": append([]string{"name:" + name}, filters...)}
+	response, err := a.doRequest("GET", endpoint+"?"+query.Encode(), nil, "", 0, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Resources []struct {
+			Metadata struct {
+				GUID string `json:"guid"`
+			} `json:"metadata"`
+		} `json:"resources"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Resources) == 0 {
+		return "", fmt.Errorf("%s %s not found", kind, name)
+	}
+	return result.Resources[0].Metadata.GUID, nil
+}
+
+// Name returns the name of the app in an app reference, for use in local
+// file names and local.yml. The name of an app referenced by GUID is
+// retrieved from the Cloud Controller.
+func (a *App) Name(ref string) (string, error) {
+	if !guidPattern.MatchString(ref) {
+		return AppName(ref), nil
+	}
+	if err := a.checkAuth(); err != nil {
+		return "", err
+	}
+	var app struct {
+		Entity struct {
+			Name string `json:"name"`
+		} `json:"entity"`
+	}
+	if err := a.getEntity("/v2/apps/"+ref, &app); err != nil {
+		return "", err
+	}
+	return app.Entity.Name, nil
+}
+
+// AppName returns the last part of an app reference, which is the name of
+// the app unless the reference is a GUID.
+func AppName(ref string) string {
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		return ref[i+1:]
+	}
+	return ref
+}
+
+// AnyInstance selects any running instance of an app.
+const AnyInstance = "any"
+
+// SplitInstance splits an app reference that ends with an instance, such as
+// <name>/2, <org>/<space>/<name>/2, or <guid>/any, into the app reference
+// and the instance. References without an instance select instance 0.
+func SplitInstance(ref string) (appRef, instance string) {
+	switch parts := strings.Split(ref, "/"); len(parts) {
+	case 2, 4:
+		return strings.Join(parts[:len(parts)-1], "/"), parts[len(parts)-1]
+	}
+	return ref, "0"
+}
+
+func validInstance(instance string) bool {
+	if instance == AnyInstance {
+		return true
+	}
+	_, err := strconv.ParseUint(instance, 10, 32)
+	return err == nil
+}
+
+// runningInstance returns the index of the first running instance of the
+// app.
+func (a *App) runningInstance(guid string) (string, error) {
+	var instances map[string]struct {
+		State string `json:"state"`
+	}
+	if err := a.getEntity(fmt.Sprintf("/v2/apps/%s/instances", guid), &instances); err != nil {
+		return "", err
+	}
+	var running []int
+	for index, instance := range instances {
+		if i, err := strconv.Atoi(index); err == nil && instance.State == "RUNNING" {
+			running = append(running, i)
+		}
+	}
+	if len(running) == 0 {
+		return "", errors.New("no running app instances")
+	}
+	sort.Ints(running)
+	return strconv.Itoa(running[0]), nil
+}
@@ -0,0 +1,98 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// DefaultRetries is the number of times that failed idempotent requests
+// are usually retried.
+const DefaultRetries = 3
+
+const defaultRetryDelay = time.Second
+
+var ErrSessionExpired = errors.New("session expired and could not be renewed: log in to Cloud Foundry again")
+
+// SessionExpiredError is returned when CC or UAA rejects the access token
+// and the request cannot be repeated with a renewed token. Reason is the
+// error that prevented renewal, if any.
+type SessionExpiredError struct {
+	Reason error
+}
+
+func (e *SessionExpiredError) Error() string {
+	if e.Reason == nil {
+		return ErrSessionExpired.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrSessionExpired, e.Reason)
+}
+
+// Unwrap returns ErrSessionExpired.
+func (e *SessionExpiredError) Unwrap() error {
+	return ErrSessionExpired
+}
+
+// IsSessionExpired returns true if err is ErrSessionExpired or a
+// SessionExpiredError.
+func IsSessionExpired(err error) bool {
+	if _, ok := err.(*SessionExpiredError); ok {
+		return true
+	}
+	return err == ErrSessionExpired
+}
+
+// TokenRefresher may be implemented by a CLI connection that can be forced
+// to obtain a new access token when the current token is rejected.
+type TokenRefresher interface {
+	RefreshAccessToken() (string, error)
+}
+
+func (a *App) refreshToken() (string, error) {
+	if refresher, ok := a.CLI.(TokenRefresher); ok {
+		return refresher.RefreshAccessToken()
+	}
+	// the cf CLI renews expired tokens when they are requested
+	return a.CLI.AccessToken()
+}
+
+// backoff waits before the next attempt, or returns ErrCancelled if the app
+// exits while waiting.
+func (a *App) backoff(attempt int) error {
+	delay := a.RetryDelay
+	if delay == 0 {
+		delay = defaultRetryDelay
+	}
+	timer := time.NewTimer(delay << uint(attempt))
+	defer timer.Stop()
+	select {
+	case <-a.Exit:
+		return ErrCancelled
+	case <-timer.C:
+		return nil
+	}
+}
+
+// replayable returns a function that provides a fresh copy of the request
+// body for each attempt, or nil if the request cannot safely be repeated.
+func replayable(method string, body io.Reader) (func() io.Reader, error) {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE":
+	default:
+		return nil, nil
+	}
+	switch b := body.(type) {
+	case nil:
+		return func() io.Reader { return nil }, nil
+	case *bytes.Buffer, *bytes.Reader:
+		contents, err := ioutil.ReadAll(b)
+		if err != nil {
+			return nil, err
+		}
+		return func() io.Reader { return bytes.NewReader(contents) }, nil
+	}
+	return nil, nil
+}
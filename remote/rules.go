@@ -0,0 +1,291 @@
+package remote
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ForwardRule describes how to forward a service instance with credentials
+// that are not otherwise recognized. Fields are selected using JSON paths
+// into the credentials, such as $.db.hosts[0].
+type ForwardRule struct {
+	// Host and Port are paths to fields that together contain an address.
+	// If the host field contains a port, Port may be empty.
+	Host string
+	Port string
+
+	// Addresses are paths to fields containing a host:port, a URL, or a
+	// list of them. Hosts without a port use the port field.
+	Addresses []string
+
+	// Forwards are addresses that are replaced wherever they appear in the
+	// credentials.
+	Forwards []ForwardAddress
+}
+
+// ForwardAddress is an address in the credentials, and the address that
+// connections to it are tunneled to. If To is empty, From is used.
+type ForwardAddress struct {
+	From string
+	To   string
+}
+
+func (r *ForwardRule) addresses(creds map[string]interface{}) (addresses []hostPort, err error) {
+	port := ""
+	if r.Port != "" {
+		value, err := getPath(creds, r.Port)
+		if err != nil {
+			return nil, err
+		}
+		if port = portString(value); port == "" {
+			return nil, fmt.Errorf("%s is not a port", r.Port)
+		}
+	}
+	withPort := func(address hostPort, path string) (hostPort, error) {
+		if address.port == "" {
+			address.port = port
+		}
+		if address.host == "" || address.port == "" {
+			return hostPort{}, fmt.Errorf("%s does not contain a host and port", path)
+		}
+		return address, nil
+	}
+
+	if r.Host != "" {
+		value, err := getPath(creds, r.Host)
+		if err != nil {
+			return nil, err
+		}
+		host, _ := value.(string)
+		address, err := withPort(parseHostPort(host), r.Host)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	} else if r.Port != "" {
+		return nil, fmt.Errorf("%s requires a host field", r.Port)
+	}
+	for _, path := range r.Addresses {
+		value, err := getPath(creds, path)
+		if err != nil {
+			return nil, err
+		}
+		found, ok := addressRewriter{}.addresses(value)
+		if !ok || len(found) == 0 {
+			return nil, fmt.Errorf("%s does not contain an address", path)
+		}
+		for _, address := range found {
+			if address, err = withPort(address, path); err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, address)
+		}
+	}
+	for _, forward := range r.Forwards {
+		address, err := withPort(parseHostPort(forward.From), forward.From)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return unique(addresses), nil
+}
+
+func unique(addresses []hostPort) (unique []hostPort) {
+	found := map[hostPort]bool{}
+	for _, address := range addresses {
+		if !found[address] {
+			found[address] = true
+			unique = append(unique, address)
+		}
+	}
+	return unique
+}
+
+// target returns the address that connections to the address are tunneled
+// to.
+func (r *ForwardRule) target(address hostPort) hostPort {
+	for _, forward := range r.Forwards {
+		if parseHostPort(forward.From) == address && forward.To != "" {
+			return parseHostPort(forward.To)
+		}
+	}
+	return address
+}
+
+// rewrite replaces the addresses selected by the rule with their forwarded
+// addresses. It must only be called after addresses succeeds.
+func (r *ForwardRule) rewrite(creds map[string]interface{}, forwarded map[hostPort]hostPort) {
+	port := ""
+	if r.Port != "" {
+		value, _ := getPath(creds, r.Port)
+		port = portString(value)
+	}
+	forward := func(address hostPort) hostPort {
+		if address.port == "" {
+			address.port = port
+		}
+		return forwarded[address]
+	}
+
+	if r.Host != "" {
+		value, _ := getPath(creds, r.Host)
+		host, _ := value.(string)
+		address := parseHostPort(host)
+		if address.port == "" {
+			setPath(creds, r.Host, forward(address).host)
+		} else {
+			setPath(creds, r.Host, forward(address).String())
+		}
+		if r.Port != "" {
+			value, _ := getPath(creds, r.Port)
+			if _, isString := value.(string); isString {
+				setPath(creds, r.Port, forward(address).port)
+			} else if p, err := strconv.ParseFloat(forward(address).port, 64); err == nil {
+				setPath(creds, r.Port, p)
+			}
+		}
+	}
+	for _, path := range r.Addresses {
+		value, _ := getPath(creds, path)
+		setPath(creds, path, addressRewriter{}.rewrite(value, forward))
+	}
+	for _, f := range r.Forwards {
+		from := parseHostPort(f.From)
+		replaceStrings(creds, from.String(), forwarded[from].String())
+	}
+}
+
+// replaceStrings replaces the address old with replacement in every string
+// in the credentials.
+func replaceStrings(value interface{}, old, replacement string) interface{} {
+	switch value := value.(type) {
+	case string:
+		return replaceAddress(value, old, replacement)
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = replaceStrings(v, old, replacement)
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = replaceStrings(v, old, replacement)
+		}
+	}
+	return value
+}
+
+// replaceAddress replaces each occurrence of the address old in s with
+// replacement. Occurrences that are part of a longer hostname or port, such
+// as old in my<old> or <old>1, are not replaced.
+func replaceAddress(s, old, replacement string) string {
+	var replaced strings.Builder
+	last := 0
+	for start := 0; start < len(s); {
+		i := strings.Index(s[start:], old)
+		if i < 0 {
+			break
+		}
+		i += start
+		end := i + len(old)
+		if (i > 0 && isAddressByte(s[i-1])) || (end < len(s) && isAddressByte(s[end])) {
+			start = i + 1
+			continue
+		}
+		replaced.WriteString(s[last:i])
+		replaced.WriteString(replacement)
+		last, start = end, end
+	}
+	replaced.WriteString(s[last:])
+	return replaced.String()
+}
+
+func isAddressByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '.' || b == '-' || b == '_'
+}
+
+// parsePath splits a JSON path like $.db.hosts[0] into map keys and array
+// indexes.
+func parsePath(path string) ([]interface{}, error) {
+	var elements []interface{}
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for rest != "" {
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end > 0 {
+			elements = append(elements, rest[:end])
+		}
+		rest = rest[end:]
+		for strings.HasPrefix(rest, "[") {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path: %s", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid path: %s", path)
+			}
+			elements = append(elements, index)
+			rest = rest[end+1:]
+		}
+		rest = strings.TrimPrefix(rest, ".")
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	return elements, nil
+}
+
+func getPath(creds map[string]interface{}, path string) (interface{}, error) {
+	elements, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{} = creds
+	for _, element := range elements {
+		switch element := element.(type) {
+		case string:
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s not found", path)
+			}
+			if value, ok = m[element]; !ok {
+				return nil, fmt.Errorf("%s not found", path)
+			}
+		case int:
+			list, ok := value.([]interface{})
+			if !ok || element >= len(list) {
+				return nil, fmt.Errorf("%s not found", path)
+			}
+			value = list[element]
+		}
+	}
+	return value, nil
+}
+
+// setPath replaces the value of an existing field.
+func setPath(creds map[string]interface{}, path string, value interface{}) {
+	elements, err := parsePath(path)
+	if err != nil {
+		return
+	}
+	parentPath := elements[:len(elements)-1]
+	var parent interface{} = creds
+	for _, element := range parentPath {
+		switch element := element.(type) {
+		case string:
+			parent = parent.(map[string]interface{})[element]
+		case int:
+			parent = parent.([]interface{})[element]
+		}
+	}
+	switch element := elements[len(elements)-1].(type) {
+	case string:
+		parent.(map[string]interface{})[element] = value
+	case int:
+		parent.([]interface{})[element] = value
+	}
+}
@@ -0,0 +1,222 @@
+package remote
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/buildpack/forge"
+)
+
+// SecretFields are the credential field names, matched case-insensitively
+// as substrings, whose values are always treated as secret.
+var SecretFields = []string{"password", "passwd", "secret", "token", "private_key", "privatekey", "api_key", "apikey", "access_key"}
+
+var (
+	urlPasswordPattern   = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]*:([^@/\s]+)@`)
+	paramPasswordPattern = regexp.MustCompile(`(?i)[?&;]password=([^&;\s]+)`)
+)
+
+// ServiceScope limits and adjusts the service bindings imported from
+// remote apps.
+type ServiceScope struct {
+	// Allow lists the names of the service instances to import. If it is
+	// empty, all service instances are imported.
+	Allow []string
+
+	// Overrides replace credential fields, selected by JSON paths such as
+	// $.db.password, keyed by service instance name.
+	Overrides map[string]map[string]interface{}
+}
+
+// Filter returns the allowed service instances.
+func (s *ServiceScope) Filter(svcs forge.Services) forge.Services {
+	if s == nil || len(s.Allow) == 0 || svcs == nil {
+		return svcs
+	}
+	allowed := map[string]bool{}
+	for _, name := range s.Allow {
+		allowed[name] = true
+	}
+	filtered := forge.Services{}
+	for _, svcType := range serviceTypes(svcs) {
+		for _, svc := range svcs[svcType] {
+			if allowed[svc.Name] {
+				filtered[svcType] = append(filtered[svcType], svc)
+			}
+		}
+	}
+	return filtered
+}
+
+// Override replaces credential fields with their overrides. Fields that do
+// not exist are added, but only to existing maps.
+func (s *ServiceScope) Override(svcs forge.Services) error {
+	if s == nil {
+		return nil
+	}
+	for _, svcType := range serviceTypes(svcs) {
+		for i, svc := range svcs[svcType] {
+			overrides := s.Overrides[svc.Name]
+			if len(overrides) == 0 {
+				continue
+			}
+			if svc.Credentials == nil {
+				svc.Credentials = map[string]interface{}{}
+				svcs[svcType][i].Credentials = svc.Credentials
+			}
+			for _, path := range sortedKeys(overrides) {
+				if err := overridePath(svc.Credentials, path, credentialValue(overrides[path])); err != nil {
+					return fmt.Errorf("unable to override credentials for %s: %s", svc.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ReplaceCredential replaces the string at path in the credentials of the
+// named service instance with the value returned by replace. It returns
+// false if there is no service instance with that name.
+func ReplaceCredential(svcs forge.Services, name, path string, replace func(value string) (string, error)) (ok bool, err error) {
+	for _, svcType := range serviceTypes(svcs) {
+		for _, svc := range svcs[svcType] {
+			if svc.Name != name {
+				continue
+			}
+			value, err := getPath(svc.Credentials, path)
+			if err != nil {
+				return true, err
+			}
+			str, isString := value.(string)
+			if !isString {
+				return true, fmt.Errorf("%s is not a string", path)
+			}
+			replacement, err := replace(str)
+			if err != nil {
+				return true, err
+			}
+			setPath(svc.Credentials, path, replacement)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func overridePath(creds map[string]interface{}, path string, value interface{}) error {
+	elements, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	parentPath := elements[:len(elements)-1]
+	var parent interface{} = creds
+	for _, element := range parentPath {
+		switch element := element.(type) {
+		case string:
+			m, ok := parent.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s not found", path)
+			}
+			if parent, ok = m[element]; !ok {
+				return fmt.Errorf("%s not found", path)
+			}
+		case int:
+			list, ok := parent.([]interface{})
+			if !ok || element >= len(list) {
+				return fmt.Errorf("%s not found", path)
+			}
+			parent = list[element]
+		}
+	}
+	switch element := elements[len(elements)-1].(type) {
+	case string:
+		m, ok := parent.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s not found", path)
+		}
+		m[element] = value
+	case int:
+		list, ok := parent.([]interface{})
+		if !ok || element >= len(list) {
+			return fmt.Errorf("%s not found", path)
+		}
+		list[element] = value
+	}
+	return nil
+}
+
+// credentialValue converts a value from local.yml into the types used by
+// JSON credentials.
+func credentialValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range value {
+			m[fmt.Sprint(k)] = credentialValue(v)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, v := range value {
+			list[i] = credentialValue(v)
+		}
+		return list
+	}
+	return value
+}
+
+// Secrets returns the secret values in the service credentials: the values
+// of fields with names that contain any of the SecretFields or the provided
+// fields, and the passwords in URLs.
+func Secrets(svcs forge.Services, fields ...string) []string {
+	var patterns []string
+	for _, field := range append(append([]string{}, SecretFields...), fields...) {
+		patterns = append(patterns, strings.ToLower(field))
+	}
+	found := map[string]bool{}
+	var walk func(value interface{}, secret bool)
+	walk = func(value interface{}, secret bool) {
+		switch value := value.(type) {
+		case string:
+			if secret {
+				found[value] = true
+			}
+			for _, pattern := range []*regexp.Regexp{urlPasswordPattern, paramPasswordPattern} {
+				for _, match := range pattern.FindAllStringSubmatch(value, -1) {
+					found[match[1]] = true
+				}
+			}
+		case map[string]interface{}:
+			for k, v := range value {
+				walk(v, secret || secretField(k, patterns))
+			}
+		case []interface{}:
+			for _, v := range value {
+				walk(v, secret)
+			}
+		}
+	}
+	for _, svcType := range serviceTypes(svcs) {
+		for _, svc := range svcs[svcType] {
+			walk(svc.Credentials, false)
+		}
+	}
+	delete(found, "")
+	var secrets []string
+	for secret := range found {
+		secrets = append(secrets, secret)
+	}
+	sort.Strings(secrets)
+	return secrets
+}
+
+func secretField(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
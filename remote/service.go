@@ -5,15 +5,13 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"net/url"
 	"sort"
 	"strconv"
-	"strings"
 
 	"github.com/buildpack/forge"
-)
 
-const firstForwardedServicePort uint = 40000
+	"code.cloudfoundry.org/cflocal/cfclient"
+)
 
 func (a *App) Services(name string) (forge.Services, error) {
 	appEnvJSON, _, err := a.get(name, "/env")
@@ -32,45 +30,134 @@ func (a *App) Services(name string) (forge.Services, error) {
 	return env.SystemEnvJSON.VCAPServices, nil
 }
 
-func (a *App) Forward(name string, svcs forge.Services) (forge.Services, *forge.ForwardDetails, error) {
+// ForwardDetails describes how to tunnel through the app's SSH endpoint.
+// It adds the fingerprint of the endpoint's host key, as reported by the
+// Cloud Controller, to the details used by forge.
+type ForwardDetails struct {
+	forge.ForwardDetails
+	HostKeyFingerprint string
+
+	login func() (user, code string, err error)
+}
+
+// Login returns the user and a one-time code for tunneling through the app.
+// Unlike Code, it does not update User, so it may be used while the details
+// are also used by forge.
+func (d *ForwardDetails) Login() (user, code string, err error) {
+	if d.login == nil {
+		code, err := d.Code()
+		return d.User, code, err
+	}
+	return d.login()
+}
+
+// Forward rewrites the service bindings to use local ports that are tunneled
+// through the app. Services with a forwarding rule are forwarded using the
+// rule instead of their recognized credentials.
+// The name may end with the instance to tunnel through (see SplitInstance).
+// When any instance is selected, each call to Code or Login selects a
+// running instance, so that reconnections use a healthy instance. Code also
+// updates User, so only one user of the details should call it.
+func (a *App) Forward(name string, svcs forge.Services, options *ForwardOptions) (forge.Services, *ForwardDetails, error) {
 	var err error
-	config := &forge.ForwardDetails{}
+	config := &ForwardDetails{}
 
-	if config.Host, config.Port, err = a.sshEndpoint(); err != nil {
+	appRef, instance := SplitInstance(name)
+	if !validInstance(instance) {
+		return nil, nil, fmt.Errorf("invalid app instance: %s", instance)
+	}
+
+	info, err := a.info()
+	if err != nil {
 		return nil, nil, err
 	}
+	if config.Host, config.Port, err = net.SplitHostPort(info.AppSSHEndpoint); err != nil {
+		return nil, nil, err
+	}
+	config.HostKeyFingerprint = info.AppSSHHostKeyFingerprint
 
 	if err := a.checkAuth(); err != nil {
 		return nil, nil, err
 	}
-	appModel, err := a.CLI.GetApp(name)
+	guid, err := a.appGUID(appRef)
 	if err != nil {
 		return nil, nil, err
 	}
-	config.User = fmt.Sprintf("cf:%s/0", appModel.Guid)
+	selectUser := func() (string, error) {
+		index := instance
+		if instance == AnyInstance {
+			var err error
+			if index, err = a.runningInstance(guid); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("cf:%s/%s", guid, index), nil
+	}
+	if config.User, err = selectUser(); err != nil {
+		return nil, nil, err
+	}
 
+	config.login = func() (user, code string, err error) {
+		if user, err = selectUser(); err != nil {
+			return "", "", err
+		}
+		if code, err = a.sshCode(info); err != nil {
+			return "", "", err
+		}
+		return user, code, nil
+	}
 	config.Code = func() (string, error) {
-		sshCodeLines, err := a.CLI.CliCommandWithoutTerminalOutput("ssh-code")
+		user, code, err := config.login()
 		if err != nil {
 			return "", err
 		}
-		return sshCodeLines[0], nil
+		config.User = user
+		return code, nil
 	}
 
-	forwardedPort := firstForwardedServicePort
+	var rules map[string]*ForwardRule
+	if options != nil {
+		rules = options.Rules
+	}
+	ports := newPortAllocator(options, a.UI.Warn)
 	for _, svcType := range serviceTypes(svcs) {
 		for i, svc := range svcs[svcType] {
 			name := fmt.Sprintf("%s:%s[%d]", svc.Name, svcType, i)
-			if address := forward(svc.Credentials, "localhost", forwardedPort); address != "" {
+			addresses, target, rewrite := forwardAddresses(svc.Credentials), noTarget, rewriteCredentials
+			if rule, ok := rules[svc.Name]; ok {
+				var err error
+				if addresses, err = rule.addresses(svc.Credentials); err != nil {
+					a.UI.Warn("unable to forward service: %s: %s", name, err)
+					continue
+				}
+				target, rewrite = rule.target, rule.rewrite
+			}
+			if len(addresses) == 0 {
+				a.UI.Warn("unable to forward service: %s", name)
+				continue
+			}
+			forwarded := map[hostPort]hostPort{}
+			for j, address := range addresses {
+				key, forwardName := svc.Name, name
+				if j > 0 {
+					key = fmt.Sprintf("%s#%d", svc.Name, j)
+				}
+				if len(addresses) > 1 {
+					forwardName = fmt.Sprintf("%s#%d", name, j)
+				}
+				port, err := ports.port(key)
+				if err != nil {
+					return nil, nil, err
+				}
+				from := strconv.FormatUint(uint64(port), 10)
+				forwarded[address] = hostPort{"localhost", from}
 				config.Forwards = append(config.Forwards, forge.Forward{
-					Name: name,
-					From: strconv.FormatUint(uint64(forwardedPort), 10),
-					To:   address,
+					Name: forwardName,
+					From: from,
+					To:   target(address).String(),
 				})
-			} else {
-				a.UI.Warn("unable to forward service: %s", name)
 			}
-			forwardedPort++
+			rewrite(svc.Credentials, forwarded)
 		}
 	}
 	if len(config.Forwards) == 0 {
@@ -79,6 +166,10 @@ func (a *App) Forward(name string, svcs forge.Services) (forge.Services, *forge.
 	return svcs, config, nil
 }
 
+func noTarget(address hostPort) hostPort {
+	return address
+}
+
 func serviceTypes(s forge.Services) (types []string) {
 	for t := range s {
 		types = append(types, t)
@@ -87,111 +178,46 @@ func serviceTypes(s forge.Services) (types []string) {
 	return
 }
 
-func (a *App) sshEndpoint() (host, port string, err error) {
+func (a *App) info() (*cfclient.Info, error) {
 	target, err := a.CLI.ApiEndpoint()
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	url := fmt.Sprintf("%s/v2/info", target)
 	request, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	response, err := a.HTTP.Do(request)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	defer response.Body.Close()
 
-	var result struct {
-		AppSSHEndpoint string `json:"app_ssh_endpoint"`
-	}
-	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
-		return "", "", err
-	}
-
-	return net.SplitHostPort(result.AppSSHEndpoint)
-}
-
-func forward(creds map[string]interface{}, toHost string, toPort uint) (fromAddress string) {
-	if creds == nil {
-		return ""
-	}
-
-	override := map[string]interface{}{}
-
-	host, port := str(creds["hostname"]), f64(creds["port"])
-	if host != "" || port != 0 {
-		override["port"] = float64(toPort)
-	}
-	if host != "" {
-		override["hostname"] = toHost
-	}
-
-	uri, jdbcURL := str(creds["uri"]), str(creds["jdbcUrl"])
-	if uri != "" {
-		u, err := url.Parse(uri)
-		if err != nil || u.Host == "" {
-			return ""
-		}
-		host, port = ensureHostPort(host, port, u.Host)
-		u.Host = fmt.Sprintf("%s:%d", toHost, toPort)
-		override["uri"] = u.String()
-	}
-	if jdbcURL != "" {
-		u, err := url.Parse(strings.TrimPrefix(jdbcURL, "jdbc:"))
-		if err != nil || u.Host == "" {
-			return ""
-		}
-		host, port = ensureHostPort(host, port, u.Host)
-		u.Host = fmt.Sprintf("%s:%d", toHost, toPort)
-		override["jdbcUrl"] = "jdbc:" + u.String()
-	}
-
-	if host == "" || port == 0 {
-		return ""
-	}
-	merge(override, creds)
-	return fmt.Sprintf("%s:%.0f", host, port)
-}
-
-func f64(v interface{}) float64 {
-	f, ok := v.(float64)
-	if !ok {
-		return 0
-	}
-	return f
-}
-
-func str(v interface{}) string {
-	s, ok := v.(string)
-	if !ok {
-		return ""
+	info := &cfclient.Info{}
+	if err := json.NewDecoder(response.Body).Decode(info); err != nil {
+		return nil, err
 	}
-	return s
+	return info, nil
 }
 
-func ensureHostPort(knownHost string, knownPort float64, address string) (host string, port float64) {
-	if h, p, err := net.SplitHostPort(address); err == nil {
-		host = h
-		if p, err := strconv.ParseFloat(p, 32); err == nil {
-			port = p
-		}
-	} else {
-		host = address
+func (a *App) sshCode(info *cfclient.Info) (string, error) {
+	token, err := a.CLI.AccessToken()
+	if err != nil {
+		return "", err
 	}
-	if knownHost != "" {
-		host = knownHost
+	uaa := &cfclient.UAA{Endpoint: info.AuthorizationEndpoint, HTTP: a.HTTP}
+	code, err := uaa.SSHCode(token, info.AppSSHOAuthClient)
+	if err != cfclient.ErrUnauthorized {
+		return code, err
 	}
-	if knownPort != 0 {
-		port = knownPort
+	if token, err = a.refreshToken(); err != nil {
+		return "", &SessionExpiredError{Reason: err}
 	}
-	return
-}
-
-func merge(from, to map[string]interface{}) {
-	for k, v := range from {
-		to[k] = v
+	code, err = uaa.SSHCode(token, info.AppSSHOAuthClient)
+	if err == cfclient.ErrUnauthorized {
+		return "", &SessionExpiredError{}
 	}
+	return code, err
 }
@@ -0,0 +1,211 @@
+package snapshot
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildpack/forge"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	fileExtension = ".snapshot"
+	formatVersion = 1
+	saltSize      = 16
+	keySize       = 32
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+)
+
+// Store keeps snapshots of remote service bindings in Dir, as
+// <name>.snapshot files. The service bindings are encrypted with a key
+// derived from the passphrase, while the snapshot info is readable without
+// the passphrase but protected against changes. Passphrase is asked to
+// confirm the passphrase when it is used to save a snapshot.
+type Store struct {
+	Dir        string
+	Passphrase func(confirm bool) (string, error)
+}
+
+// Info describes a snapshot.
+type Info struct {
+	Name    string    `json:"name"`
+	App     string    `json:"app"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// Expired returns true if the snapshot has expired.
+func (i *Info) Expired() bool {
+	return !i.Expires.IsZero() && time.Now().After(i.Expires)
+}
+
+type file struct {
+	Version    int    `json:"version"`
+	Info       Info   `json:"info"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Save encrypts the service bindings and saves them as the named snapshot,
+// replacing any existing snapshot with the same name.
+func (s *Store) Save(info Info, services forge.Services) error {
+	if err := checkName(info.Name); err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(services)
+	if err != nil {
+		return err
+	}
+	f := &file{Version: formatVersion, Info: info, Salt: make([]byte, saltSize)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := s.cipher(f.Salt, true)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	data, err := additionalData(&f.Info)
+	if err != nil {
+		return err
+	}
+	f.Ciphertext = gcm.Seal(nil, f.Nonce, plaintext, data)
+
+	contents, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	tmpPath := s.path(info.Name) + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, contents, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path(info.Name))
+}
+
+// Load decrypts and returns the service bindings in the named snapshot.
+// Expired snapshots are not loaded.
+func (s *Store) Load(name string) (forge.Services, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	f, err := s.read(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("services snapshot %s not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	if f.Info.Expired() {
+		return nil, fmt.Errorf("services snapshot %s expired at %s", name, f.Info.Expires.Format(time.RFC3339))
+	}
+	gcm, err := s.cipher(f.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	data, err := additionalData(&f.Info)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, f.Nonce, f.Ciphertext, data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt services snapshot %s: wrong passphrase or modified file", name)
+	}
+	var services forge.Services
+	if err := json.Unmarshal(plaintext, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// List returns the info of each snapshot, sorted by name. The passphrase is
+// not required.
+func (s *Store) List() ([]Info, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*"+fileExtension))
+	if err != nil {
+		return nil, err
+	}
+	var infos []Info
+	for _, path := range paths {
+		f, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, f.Info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+func (s *Store) read(path string) (*file, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &file{}
+	if err := json.Unmarshal(contents, f); err != nil {
+		return nil, fmt.Errorf("invalid services snapshot %s: %s", filepath.Base(path), err)
+	}
+	if f.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported services snapshot version: %d", f.Version)
+	}
+	return f, nil
+}
+
+func (s *Store) cipher(salt []byte, confirm bool) (cipher.AEAD, error) {
+	if s.Passphrase == nil {
+		return nil, errors.New("services snapshot passphrase required")
+	}
+	passphrase, err := s.Passphrase(confirm)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New("services snapshot passphrase required")
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.Dir, name+fileExtension)
+}
+
+// additionalData authenticates the snapshot info, so that changes to the
+// name, app, or expiry time prevent the snapshot from being decrypted.
+func additionalData(info *Info) ([]byte, error) {
+	return json.Marshal(info)
+}
+
+func checkName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid services snapshot name: %s", name)
+	}
+	return nil
+}
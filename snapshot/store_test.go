@@ -0,0 +1,120 @@
+package snapshot_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildpack/forge"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cflocal/snapshot"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		tempDir  string
+		store    *Store
+		services forge.Services
+		info     Info
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "cflocal.snapshot")
+		Expect(err).NotTo(HaveOccurred())
+		store = &Store{
+			Dir:        filepath.Join(tempDir, "services"),
+			Passphrase: func(bool) (string, error) { return "some-passphrase", nil },
+		}
+		services = forge.Services{
+			"some-type": {{Name: "some-name", Credentials: map[string]interface{}{"password": "some-password"}}},
+		}
+		info = Info{
+			Name:    "some-snapshot",
+			App:     "some-app",
+			Created: time.Now().UTC().Truncate(time.Second),
+			Expires: time.Now().UTC().Truncate(time.Second).Add(time.Hour),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	It("should save and load encrypted service bindings", func() {
+		Expect(store.Save(info, services)).To(Succeed())
+
+		contents, err := ioutil.ReadFile(filepath.Join(tempDir, "services", "some-snapshot.snapshot"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).NotTo(ContainSubstring("some-password"))
+		stat, err := os.Stat(filepath.Join(tempDir, "services", "some-snapshot.snapshot"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		Expect(store.Load("some-snapshot")).To(Equal(services))
+	})
+
+	It("should only confirm the passphrase when saving", func() {
+		var confirms []bool
+		store.Passphrase = func(confirm bool) (string, error) {
+			confirms = append(confirms, confirm)
+			return "some-passphrase", nil
+		}
+		Expect(store.Save(info, services)).To(Succeed())
+		Expect(store.Load("some-snapshot")).To(Equal(services))
+		Expect(confirms).To(Equal([]bool{true, false}))
+	})
+
+	It("should list snapshots without the passphrase", func() {
+		Expect(store.Save(info, services)).To(Succeed())
+		otherInfo := info
+		otherInfo.Name = "some-other-snapshot"
+		Expect(store.Save(otherInfo, services)).To(Succeed())
+
+		store.Passphrase = nil
+		Expect(store.List()).To(Equal([]Info{otherInfo, info}))
+	})
+
+	It("should not load expired snapshots", func() {
+		info.Expires = time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		Expect(store.Save(info, services)).To(Succeed())
+		_, err := store.Load("some-snapshot")
+		Expect(err).To(MatchError(HavePrefix("services snapshot some-snapshot expired at ")))
+	})
+
+	It("should not load snapshots with the wrong passphrase or modified info", func() {
+		Expect(store.Save(info, services)).To(Succeed())
+		store.Passphrase = func(bool) (string, error) { return "some-wrong-passphrase", nil }
+		_, err := store.Load("some-snapshot")
+		Expect(err).To(MatchError("unable to decrypt services snapshot some-snapshot: wrong passphrase or modified file"))
+
+		store.Passphrase = func(bool) (string, error) { return "some-passphrase", nil }
+		path := filepath.Join(tempDir, "services", "some-snapshot.snapshot")
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		modified := strings.Replace(string(contents), `"app":"some-app"`, `"app":"some-other-app"`, 1)
+		Expect(modified).NotTo(Equal(string(contents)))
+		Expect(ioutil.WriteFile(path, []byte(modified), 0600)).To(Succeed())
+		_, err = store.Load("some-snapshot")
+		Expect(err).To(MatchError("unable to decrypt services snapshot some-snapshot: wrong passphrase or modified file"))
+	})
+
+	It("should return an error when the passphrase is unavailable", func() {
+		store.Passphrase = func(bool) (string, error) { return "", errors.New("some-error") }
+		Expect(store.Save(info, services)).To(MatchError("some-error"))
+	})
+
+	It("should return an error when the snapshot does not exist", func() {
+		_, err := store.Load("some-missing-snapshot")
+		Expect(err).To(MatchError("services snapshot some-missing-snapshot not found"))
+	})
+
+	It("should reject snapshot names that are not file names", func() {
+		Expect(store.Save(Info{Name: "../some-name"}, services)).To(MatchError("invalid services snapshot name: ../some-name"))
+	})
+})
@@ -0,0 +1,283 @@
+package stub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unicode/utf8"
+)
+
+const (
+	Record = "record"
+	Replay = "replay"
+
+	defaultIP   = "127.0.0.1"
+	defaultHost = "host.docker.internal"
+	linuxBridge = "docker0"
+)
+
+// sensitiveHeaders are removed from recorded responses.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"Www-Authenticate",
+}
+
+// Stubber starts HTTP servers that stand in for HTTP services. Recorded
+// responses are kept in Dir, in a directory for each service.
+type Stubber struct {
+	Dir  string
+	Logs io.Writer
+
+	// IP is the interface IP that stubs listen on.
+	// Default: the docker0 bridge IP on Linux, otherwise 127.0.0.1
+	IP string
+
+	// Host is the hostname or IP that containers use to reach the stubs.
+	// Default: the docker0 bridge IP on Linux, otherwise host.docker.internal
+	Host string
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type recordedResponse struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"body_base64,omitempty"`
+}
+
+// Stub starts a stub for the named service, and returns the URL that
+// replaces target in the service's credentials. The URL keeps the user,
+// path, and query of target. In record mode, requests are proxied to
+// target and the responses are saved. In replay mode, the saved responses
+// are served. The stub stops when done is called.
+func (s *Stubber) Stub(name, mode, target string) (stubURL string, done func(), err error) {
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.Host == "" {
+		return "", nil, fmt.Errorf("invalid URL for %s", name)
+	}
+	var handler http.Handler
+	switch mode {
+	case Record:
+		handler = s.record(name, targetURL)
+	case Replay, "":
+		handler = s.replay(name)
+	default:
+		return "", nil, fmt.Errorf("invalid stub mode for %s: %s", name, mode)
+	}
+
+	ip, host, err := s.address()
+	if err != nil {
+		return "", nil, err
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		return "", nil, err
+	}
+	server := &http.Server{
+		Handler:  handler,
+		ErrorLog: log.New(s.logs(), fmt.Sprintf("[%s] ", name), 0),
+	}
+	go server.Serve(listener)
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		server.Close()
+		return "", nil, err
+	}
+	stubbed := *targetURL
+	stubbed.Scheme = "http"
+	stubbed.Host = net.JoinHostPort(host, port)
+	return stubbed.String(), func() { server.Close() }, nil
+}
+
+func (s *Stubber) record(name string, target *url.URL) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		key := requestKey(req, body)
+		recorded := recordedRequest{Method: req.Method, URL: req.URL.RequestURI()}
+
+		proxy := &httputil.ReverseProxy{
+			Director: func(out *http.Request) {
+				out.URL.Scheme = target.Scheme
+				out.URL.Host = target.Host
+				out.Host = target.Host
+			},
+			ModifyResponse: func(res *http.Response) error {
+				resBody, err := ioutil.ReadAll(res.Body)
+				res.Body.Close()
+				if err != nil {
+					return err
+				}
+				res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+				response := recordedResponse{Status: res.StatusCode, Header: recordedHeader(res.Header)}
+				if utf8.Valid(resBody) {
+					response.Body = string(resBody)
+				} else {
+					response.BodyBase64 = resBody
+				}
+				if err := s.save(name, key, &interaction{recorded, response}); err != nil {
+					fmt.Fprintf(s.logs(), "[%s] failed to record %s %s: %s\n", name, recorded.Method, recorded.URL, err)
+				} else {
+					fmt.Fprintf(s.logs(), "[%s] recorded %s %s: %d\n", name, recorded.Method, recorded.URL, res.StatusCode)
+				}
+				return nil
+			},
+			ErrorLog: log.New(s.logs(), fmt.Sprintf("[%s] ", name), 0),
+		}
+		proxy.ServeHTTP(w, req)
+	})
+}
+
+func (s *Stubber) replay(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recorded, err := s.load(name, requestKey(req, body))
+		if err != nil {
+			fmt.Fprintf(s.logs(), "[%s] no recorded response for %s %s\n", name, req.Method, req.URL.RequestURI())
+			http.Error(w, "no recorded response", http.StatusBadGateway)
+			return
+		}
+		for k, v := range recorded.Response.Header {
+			w.Header()[k] = v
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(recorded.Response.Status)
+		if recorded.Response.BodyBase64 != nil {
+			w.Write(recorded.Response.BodyBase64)
+		} else {
+			io.WriteString(w, recorded.Response.Body)
+		}
+	})
+}
+
+// recordedHeader returns a copy of the header without credentials.
+func recordedHeader(header http.Header) http.Header {
+	recorded := http.Header{}
+	for k, v := range header {
+		recorded[k] = v
+	}
+	for _, k := range sensitiveHeaders {
+		recorded.Del(k)
+	}
+	return recorded
+}
+
+// requestKey identifies a request by its method, path, query, and body.
+// Headers are not included, so credentials sent in headers are never saved.
+func requestKey(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s?%s\n", req.Method, req.URL.Path, req.URL.Query().Encode())
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+func (s *Stubber) save(name, key string, i *interaction) error {
+	contents, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := s.dir(name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, key+".json"), contents, 0600)
+}
+
+func (s *Stubber) load(name, key string) (*interaction, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(s.dir(name), key+".json"))
+	if err != nil {
+		return nil, err
+	}
+	i := &interaction{}
+	if err := json.Unmarshal(contents, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (s *Stubber) dir(name string) string {
+	return filepath.Join(s.Dir, url.PathEscape(name))
+}
+
+func (s *Stubber) logs() io.Writer {
+	if s.Logs == nil {
+		return ioutil.Discard
+	}
+	return s.Logs
+}
+
+// address returns the IP that stubs listen on and the host that containers
+// use to reach them. On Linux, host.docker.internal does not resolve and
+// containers cannot reach 127.0.0.1 on the host, so the default is the IP of
+// the docker0 bridge.
+func (s *Stubber) address() (ip, host string, err error) {
+	ip, host = s.IP, s.Host
+	if ip != "" && host != "" {
+		return ip, host, nil
+	}
+	defaultIP, defaultHost := defaultIP, defaultHost
+	if runtime.GOOS == "linux" {
+		bridgeIP, err := interfaceIP(linuxBridge)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to find the %s bridge for HTTP service stubs, set CFL_STUB_IP and CFL_STUB_HOST: %s", linuxBridge, err)
+		}
+		defaultIP, defaultHost = bridgeIP, bridgeIP
+	}
+	if ip == "" {
+		ip = defaultIP
+	}
+	if host == "" {
+		host = defaultHost
+	}
+	return ip, host, nil
+}
+
+func interfaceIP(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no IPv4 address", name)
+}
@@ -0,0 +1,134 @@
+package stub_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	. "code.cloudfoundry.org/cflocal/stub"
+)
+
+var _ = Describe("Stubber", func() {
+	var (
+		tempDir string
+		logs    *gbytes.Buffer
+		stubber *Stubber
+		server  *httptest.Server
+		hits    int32
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "cflocal.stub")
+		Expect(err).NotTo(HaveOccurred())
+		logs = gbytes.NewBuffer()
+		stubber = &Stubber{Dir: tempDir, Logs: logs, IP: "127.0.0.1", Host: "127.0.0.1"}
+		hits = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			body, _ := ioutil.ReadAll(req.Body)
+			w.Header().Set("Some-Header", "some-value")
+			w.Header().Set("Set-Cookie", "session=some-session")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("response to " + req.Method + " " + req.URL.RequestURI() + " " + string(body)))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	request := func(method, url, body string) (*http.Response, string) {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		res, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+		resBody, err := ioutil.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return res, string(resBody)
+	}
+
+	Describe("#Stub", func() {
+		It("should record responses from the service and replay them", func() {
+			recordURL, done, err := stubber.Stub("some-service", "record", server.URL+"/some-path?a=b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recordURL).To(MatchRegexp(`^http://127\.0\.0\.1:\d+/some-path\?a=b$`))
+
+			res, body := request("POST", recordURL, "some-body")
+			Expect(res.StatusCode).To(Equal(http.StatusCreated))
+			Expect(res.Header.Get("Some-Header")).To(Equal("some-value"))
+			Expect(body).To(Equal("response to POST /some-path?a=b some-body"))
+			Expect(logs).To(gbytes.Say(`\[some-service\] recorded POST /some-path\?a=b: 201`))
+			done()
+
+			replayURL, done, err := stubber.Stub("some-service", "replay", server.URL+"/some-path?a=b")
+			Expect(err).NotTo(HaveOccurred())
+			defer done()
+
+			res, body = request("POST", replayURL, "some-body")
+			Expect(res.StatusCode).To(Equal(http.StatusCreated))
+			Expect(res.Header.Get("Some-Header")).To(Equal("some-value"))
+			Expect(body).To(Equal("response to POST /some-path?a=b some-body"))
+			Expect(atomic.LoadInt32(&hits)).To(Equal(int32(1)))
+		})
+
+		It("should not record credentials in response headers", func() {
+			recordURL, done, err := stubber.Stub("some-service", "record", server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			res, _ := request("GET", recordURL, "")
+			Expect(res.Header.Get("Set-Cookie")).To(Equal("session=some-session"))
+			done()
+
+			files, err := ioutil.ReadDir(filepath.Join(tempDir, "some-service"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+			fixture, err := ioutil.ReadFile(filepath.Join(tempDir, "some-service", files[0].Name()))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(fixture)).To(ContainSubstring("some-value"))
+			Expect(string(fixture)).NotTo(ContainSubstring("some-session"))
+
+			replayURL, done, err := stubber.Stub("some-service", "replay", server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			defer done()
+			res, _ = request("GET", replayURL, "")
+			Expect(res.Header.Get("Set-Cookie")).To(BeEmpty())
+		})
+
+		It("should return an error in replay mode when a request was not recorded", func() {
+			replayURL, done, err := stubber.Stub("some-service", "replay", server.URL+"/some-path")
+			Expect(err).NotTo(HaveOccurred())
+			defer done()
+
+			res, _ := request("GET", replayURL, "")
+			Expect(res.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(logs).To(gbytes.Say(`\[some-service\] no recorded response for GET /some-path`))
+			Expect(atomic.LoadInt32(&hits)).To(Equal(int32(0)))
+		})
+
+		It("should stop serving when done is called", func() {
+			replayURL, done, err := stubber.Stub("some-service", "replay", server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			done()
+
+			_, err = http.Get(replayURL)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error when the mode or URL is invalid", func() {
+			_, _, err := stubber.Stub("some-service", "some-mode", server.URL)
+			Expect(err).To(MatchError("invalid stub mode for some-service: some-mode"))
+			_, _, err = stubber.Stub("some-service", "replay", "some-path")
+			Expect(err).To(MatchError("invalid URL for some-service"))
+		})
+	})
+})
@@ -12,9 +12,11 @@ import (
 type Request struct {
 	Method        string
 	Path          string
+	Query         string
 	Authenticated bool
 	ContentType   string
 	ContentLength int64
+	Range         string
 	Body          string
 }
 
@@ -33,7 +35,9 @@ func (s *Server) Handle(auth bool, status int, response string) (*Request, Calls
 		*request = Request{
 			Method:        r.Method,
 			Path:          r.URL.Path,
+			Query:         r.URL.RawQuery,
 			Authenticated: auth && r.Header.Get("Authorization") == accessToken,
+			Range:         r.Header.Get("Range"),
 		}
 		if r.Method == "PUT" || r.Method == "POST" {
 			defer r.Body.Close()
@@ -0,0 +1,292 @@
+package tunnel
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildpack/forge"
+	"golang.org/x/crypto/ssh"
+
+	"code.cloudfoundry.org/cflocal/remote"
+)
+
+const (
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 30 * time.Second
+	defaultKeepAlive  = 15 * time.Second
+	dialTimeout       = 30 * time.Second
+
+	md5FingerprintLength    = 47 // colon-separated hex
+	sha1FingerprintLength   = 59 // colon-separated hex
+	sha256FingerprintLength = 43 // base64 without padding
+)
+
+// Tunneler opens SSH forwards as listeners on the host, so that forwarded
+// services are reachable from outside of any container.
+type Tunneler struct {
+	Logs io.Writer
+
+	// RetryDelay is the delay before reconnecting when the SSH session is
+	// lost. It is doubled after each failed attempt, up to 30 seconds.
+	// Default: 1 second
+	RetryDelay time.Duration
+
+	// KeepAlive is the interval between checks that the SSH session is
+	// still responding.
+	// Default: 15 seconds
+	KeepAlive time.Duration
+}
+
+// Tunnel listens on ip at the From port of each forward, and tunnels each
+// connection through the app's SSH endpoint to the forward's To address.
+// If the SSH session is lost, it is reopened with a new code. The health
+// channel holds the latest status of the session, either "healthy" or
+// "unhealthy". Listening stops when done is called.
+func (t *Tunneler) Tunnel(details *remote.ForwardDetails, ip string) (health <-chan string, done func(), err error) {
+	client, err := t.connect(details)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var listeners []net.Listener
+	closeListeners := func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}
+	for _, forward := range details.Forwards {
+		listener, err := net.Listen("tcp", net.JoinHostPort(ip, forward.From))
+		if err != nil {
+			closeListeners()
+			client.Close()
+			return nil, nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	s := &session{client: client}
+	status := make(chan string, 1)
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.maintain(s, details, status, stop)
+	}()
+	for i, forward := range details.Forwards {
+		wg.Add(1)
+		go func(listener net.Listener, forward forge.Forward) {
+			defer wg.Done()
+			t.accept(s, listener, forward)
+		}(listeners[i], forward)
+	}
+	return status, func() {
+		close(stop)
+		closeListeners()
+		wg.Wait()
+	}, nil
+}
+
+func (t *Tunneler) connect(details *remote.ForwardDetails) (*ssh.Client, error) {
+	user, code, err := details.Login()
+	if err != nil {
+		return nil, err
+	}
+	return ssh.Dial("tcp", net.JoinHostPort(details.Host, details.Port), &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.Password(code)},
+		HostKeyCallback: fingerprintCallback(details.HostKeyFingerprint),
+		Timeout:         dialTimeout,
+	})
+}
+
+// fingerprintCallback rejects host keys that do not match the fingerprint,
+// which may be an MD5 or SHA-1 fingerprint in colon-separated hex, or a
+// base64 SHA-256 fingerprint, as with cf ssh. Any host key is accepted when
+// the Cloud Controller does not provide a fingerprint.
+func fingerprintCallback(expected string) ssh.HostKeyCallback {
+	return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+		var fingerprint string
+		switch len(expected) {
+		case 0:
+			return nil
+		case sha256FingerprintLength:
+			sum := sha256.Sum256(key.Marshal())
+			fingerprint = base64.RawStdEncoding.EncodeToString(sum[:])
+		case sha1FingerprintLength:
+			sum := sha1.Sum(key.Marshal())
+			fingerprint = hexFingerprint(sum[:])
+		case md5FingerprintLength:
+			sum := md5.Sum(key.Marshal())
+			fingerprint = hexFingerprint(sum[:])
+		default:
+			return errors.New("unsupported host key fingerprint format")
+		}
+		if !strings.EqualFold(fingerprint, expected) {
+			return fmt.Errorf("host key verification failed for %s", hostname)
+		}
+		return nil
+	}
+}
+
+func hexFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// maintain reports the health of the SSH session and reconnects when the
+// session is lost, until stop is closed.
+func (t *Tunneler) maintain(s *session, details *remote.ForwardDetails, status chan string, stop <-chan struct{}) {
+	report := func(health string) {
+		select {
+		case <-status:
+		default:
+		}
+		status <- health
+	}
+	for {
+		report("healthy")
+		if !t.wait(s.get(), stop) {
+			return
+		}
+		report("unhealthy")
+		fmt.Fprintln(t.logs(), "[tunnel] connection lost, reconnecting")
+
+		delay := t.retryDelay()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+			client, err := t.connect(details)
+			if err == nil {
+				s.set(client)
+				break
+			}
+			fmt.Fprintf(t.logs(), "[tunnel] failed to reconnect: %s\n", err)
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+		}
+	}
+}
+
+// wait returns false when stop is closed, or true when the SSH session is
+// lost. The client is closed in either case.
+func (t *Tunneler) wait(client *ssh.Client, stop <-chan struct{}) bool {
+	defer client.Close()
+	lost := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(lost)
+	}()
+	ticker := time.NewTicker(t.keepAlive())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-lost:
+			return true
+		case <-ticker.C:
+			if err := keepAlive(client, t.keepAlive()); err != nil {
+				return true
+			}
+		}
+	}
+}
+
+func keepAlive(client *ssh.Client, timeout time.Duration) error {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+	select {
+	case err := <-reply:
+		return err
+	case <-time.After(timeout):
+		return errors.New("keepalive timed out")
+	}
+}
+
+func (t *Tunneler) accept(s *session, listener net.Listener, forward forge.Forward) {
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer local.Close()
+			remote, err := s.get().Dial("tcp", forward.To)
+			if err != nil {
+				fmt.Fprintf(t.logs(), "[%s] failed to connect to %s: %s\n", forward.Name, forward.To, err)
+				return
+			}
+			defer remote.Close()
+			copied := make(chan struct{}, 2)
+			go func() {
+				io.Copy(remote, local)
+				copied <- struct{}{}
+			}()
+			go func() {
+				io.Copy(local, remote)
+				copied <- struct{}{}
+			}()
+			<-copied
+		}()
+	}
+}
+
+func (t *Tunneler) logs() io.Writer {
+	if t.Logs == nil {
+		return ioutil.Discard
+	}
+	return t.Logs
+}
+
+func (t *Tunneler) retryDelay() time.Duration {
+	if t.RetryDelay == 0 {
+		return defaultRetryDelay
+	}
+	return t.RetryDelay
+}
+
+func (t *Tunneler) keepAlive() time.Duration {
+	if t.KeepAlive == 0 {
+		return defaultKeepAlive
+	}
+	return t.KeepAlive
+}
+
+// session holds the current SSH client, which is replaced on reconnection.
+type session struct {
+	mutex  sync.Mutex
+	client *ssh.Client
+}
+
+func (s *session) get() *ssh.Client {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.client
+}
+
+func (s *session) set(client *ssh.Client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.client = client
+}
@@ -0,0 +1,273 @@
+package tunnel_test
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buildpack/forge"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"golang.org/x/crypto/ssh"
+
+	"code.cloudfoundry.org/cflocal/remote"
+	. "code.cloudfoundry.org/cflocal/tunnel"
+)
+
+var _ = Describe("Tunneler", func() {
+	var (
+		sshServer    *sshServer
+		echoListener net.Listener
+		echoAddress  string
+		tunneler     *Tunneler
+		logs         *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		echoListener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		go func() {
+			for {
+				conn, err := echoListener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					line, _ := bufio.NewReader(conn).ReadString('\n')
+					fmt.Fprintf(conn, "echo: %s", line)
+				}()
+			}
+		}()
+		echoAddress = echoListener.Addr().String()
+
+		sshServer = startSSHServer("cf:some-guid/0", "some-code")
+		logs = gbytes.NewBuffer()
+		tunneler = &Tunneler{Logs: logs}
+	})
+
+	AfterEach(func() {
+		sshServer.Close()
+		echoListener.Close()
+	})
+
+	details := func(forwards ...forge.Forward) *remote.ForwardDetails {
+		host, port, err := net.SplitHostPort(sshServer.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		return &remote.ForwardDetails{
+			ForwardDetails: forge.ForwardDetails{
+				Host:     host,
+				Port:     port,
+				User:     "cf:some-guid/0",
+				Code:     func() (string, error) { return "some-code", nil },
+				Forwards: forwards,
+			},
+			HostKeyFingerprint: strings.TrimPrefix(ssh.FingerprintSHA256(sshServer.hostKey), "SHA256:"),
+		}
+	}
+
+	Describe("#Tunnel", func() {
+		It("should tunnel connections on the host to the forwarded addresses", func() {
+			port := freePort()
+			_, done, err := tunneler.Tunnel(details(forge.Forward{Name: "some-service", From: port, To: echoAddress}), "127.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+			Expect(err).NotTo(HaveOccurred())
+			fmt.Fprintln(conn, "some-message")
+			reply, err := bufio.NewReader(conn).ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reply).To(Equal("echo: some-message\n"))
+			conn.Close()
+
+			done()
+			_, err = net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reconnect with a new code when the session is lost", func() {
+			tunneler.RetryDelay = 100 * time.Millisecond
+			port := freePort()
+			forwardDetails := details(forge.Forward{Name: "some-service", From: port, To: echoAddress})
+			var codes int32
+			forwardDetails.Code = func() (string, error) {
+				atomic.AddInt32(&codes, 1)
+				return "some-code", nil
+			}
+			health, done, err := tunneler.Tunnel(forwardDetails, "127.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			defer done()
+			Eventually(health).Should(Receive(Equal("healthy")))
+
+			sshServer.Drop()
+			Eventually(health).Should(Receive(Equal("unhealthy")))
+			Eventually(logs).Should(gbytes.Say(`\[tunnel\] connection lost, reconnecting`))
+			Eventually(health).Should(Receive(Equal("healthy")))
+			Expect(atomic.LoadInt32(&codes)).To(Equal(int32(2)))
+
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+			fmt.Fprintln(conn, "some-message")
+			reply, err := bufio.NewReader(conn).ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reply).To(Equal("echo: some-message\n"))
+		})
+
+		It("should log connections that cannot be forwarded", func() {
+			port := freePort()
+			_, done, err := tunneler.Tunnel(details(forge.Forward{Name: "some-service", From: port, To: "127.0.0.1:1"}), "127.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			defer done()
+
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+			Eventually(logs).Should(gbytes.Say(`\[some-service\] failed to connect to 127.0.0.1:1`))
+		})
+
+		It("should accept MD5 host key fingerprints", func() {
+			forwardDetails := details()
+			forwardDetails.HostKeyFingerprint = ssh.FingerprintLegacyMD5(sshServer.hostKey)
+			_, done, err := tunneler.Tunnel(forwardDetails, "127.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			done()
+		})
+
+		It("should return an error when the host key does not match the fingerprint", func() {
+			forwardDetails := details()
+			forwardDetails.HostKeyFingerprint = strings.Repeat("00:", 15) + "00"
+			_, _, err := tunneler.Tunnel(forwardDetails, "127.0.0.1")
+			Expect(err).To(MatchError(ContainSubstring("host key verification failed")))
+		})
+
+		It("should return an error when the ssh code cannot be retrieved", func() {
+			forwardDetails := details()
+			forwardDetails.Code = func() (string, error) { return "", errors.New("some-error") }
+			_, _, err := tunneler.Tunnel(forwardDetails, "127.0.0.1")
+			Expect(err).To(MatchError("some-error"))
+		})
+
+		It("should return an error when a local port is in use", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+			_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+			_, _, err = tunneler.Tunnel(details(forge.Forward{Name: "some-service", From: port, To: echoAddress}), "127.0.0.1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func freePort() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+type sshServer struct {
+	net.Listener
+	hostKey ssh.PublicKey
+	mutex   sync.Mutex
+	conns   []net.Conn
+}
+
+// startSSHServer starts an SSH server that only supports direct-tcpip
+// channels, like the forwarding done by the CF SSH proxy.
+func startSSHServer(user, password string) *sshServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+	signer, err := ssh.NewSignerFromKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if meta.User() != user || string(pass) != password {
+				return nil, errors.New("unauthorized")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	server := &sshServer{Listener: listener, hostKey: signer.PublicKey()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mutex.Lock()
+			server.conns = append(server.conns, conn)
+			server.mutex.Unlock()
+			go serveSSH(conn, config)
+		}
+	}()
+	return server
+}
+
+// Drop closes all open SSH connections.
+func (s *sshServer) Drop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *sshServer) Close() error {
+	s.Drop()
+	return s.Listener.Close()
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if newChannel.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			defer channel.Close()
+			defer remote.Close()
+			go io.Copy(remote, channel)
+			io.Copy(channel, remote)
+		}()
+	}
+}
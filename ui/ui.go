@@ -2,9 +2,12 @@ package ui
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/buildpack/forge/engine"
@@ -29,11 +32,20 @@ type UI struct {
 	Err       io.Writer
 	In        io.Reader
 	ErrIsTerm bool
+	JSON      bool
+
+	eventMutex sync.Mutex
 }
 
+type event map[string]interface{}
+
 func (u *UI) Prompt(message string) string {
 	in := bufio.NewReader(u.In)
-	fmt.Fprint(u.Out, message+" ")
+	if u.JSON {
+		u.event("prompt", event{"message": message})
+	} else {
+		fmt.Fprint(u.Out, message+" ")
+	}
 	text, err := in.ReadString('\n')
 	if err != nil {
 		return ""
@@ -42,10 +54,29 @@ func (u *UI) Prompt(message string) string {
 }
 
 func (u *UI) Output(format string, a ...interface{}) {
+	if u.JSON {
+		u.event("output", event{"message": fmt.Sprintf(format, a...)})
+		return
+	}
 	fmt.Fprintf(u.Out, format+"\n", a...)
 }
 
+func (u *UI) Result(result string, fields map[string]interface{}) {
+	if !u.JSON {
+		return
+	}
+	e := event{"result": result}
+	for k, v := range fields {
+		e[k] = v
+	}
+	u.event("result", e)
+}
+
 func (u *UI) Warn(format string, a ...interface{}) {
+	if u.JSON {
+		u.event("warning", event{"message": fmt.Sprintf(format, a...)})
+		return
+	}
 	writer := u.Err
 	if !u.ErrIsTerm {
 		// use u.Out with pre-6.22.0 cf CLI
@@ -55,6 +86,10 @@ func (u *UI) Warn(format string, a ...interface{}) {
 }
 
 func (u *UI) Error(err error) {
+	if u.JSON {
+		u.event("error", event{"message": err.Error()})
+		return
+	}
 	writer := u.Err
 	if !u.ErrIsTerm {
 		// use u.Out with pre-6.22.0 cf CLI
@@ -65,6 +100,9 @@ func (u *UI) Error(err error) {
 }
 
 func (u *UI) Loading(message string, progress <-chan engine.Progress) (err error) {
+	if u.JSON {
+		return u.loadingEvents(message, progress)
+	}
 	loadLen := len(message+loaderPrefix) + loaderWidth
 	spinLen := len(message+spinnerPrefix) + spinnerWidth*len(spinner[0])
 
@@ -110,6 +148,58 @@ func (u *UI) Loading(message string, progress <-chan engine.Progress) (err error
 	}
 }
 
+func (u *UI) loadingEvents(message string, progress <-chan engine.Progress) (err error) {
+	var last string
+	for p := range progress {
+		status, pErr := p.Status()
+		if pErr != nil {
+			err = pErr
+			continue
+		}
+		if status != last {
+			u.event("progress", event{"message": message, "status": status})
+			last = status
+		}
+	}
+	return err
+}
+
+// Logs returns a writer for container output. In JSON mode, each line
+// written is emitted as a separate log event.
+func (u *UI) Logs(source string) io.Writer {
+	if !u.JSON {
+		return u.Out
+	}
+	return &logWriter{ui: u, source: source}
+}
+
+type logWriter struct {
+	ui     *UI
+	source string
+	buf    []byte
+}
+
+func (l *logWriter) Write(p []byte) (n int, err error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimSuffix(string(l.buf[:i]), "\r")
+		l.buf = l.buf[i+1:]
+		l.ui.event("log", event{"source": l.source, "line": line})
+	}
+}
+
+func (u *UI) event(eventType string, e event) {
+	e["type"] = eventType
+	e["time"] = time.Now().UTC().Format(time.RFC3339)
+	u.eventMutex.Lock()
+	defer u.eventMutex.Unlock()
+	json.NewEncoder(u.Out).Encode(e)
+}
+
 func max(i, j int) int {
 	if i > j {
 		return i
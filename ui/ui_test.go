@@ -3,6 +3,7 @@ package ui_test
 import (
 	"errors"
 	"io"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -108,6 +109,50 @@ var _ = Describe("UI", func() {
 
 		// TODO: test loading bar
 	})
+
+	Context("when JSON output is enabled", func() {
+		BeforeEach(func() {
+			ui.JSON = true
+		})
+
+		It("should output each message as a JSON event", func() {
+			ui.Output("%s output", "some")
+			ui.Warn("%s warning", "some")
+			ui.Error(errors.New("some error"))
+			ui.Result("some-result", map[string]interface{}{"some-key": "some-value"})
+
+			lines := strings.Split(strings.TrimSpace(string(out.Contents())), "\n")
+			Expect(lines).To(HaveLen(4))
+			Expect(lines[0]).To(MatchRegexp(`"type":"output"`))
+			Expect(lines[0]).To(MatchRegexp(`"message":"some output"`))
+			Expect(lines[1]).To(MatchRegexp(`"type":"warning"`))
+			Expect(lines[1]).To(MatchRegexp(`"message":"some warning"`))
+			Expect(lines[2]).To(MatchRegexp(`"type":"error"`))
+			Expect(lines[2]).To(MatchRegexp(`"message":"some error"`))
+			Expect(lines[3]).To(MatchRegexp(`"type":"result"`))
+			Expect(lines[3]).To(MatchRegexp(`"result":"some-result"`))
+			Expect(lines[3]).To(MatchRegexp(`"some-key":"some-value"`))
+			Expect(err.Contents()).To(BeEmpty())
+		})
+
+		It("should output loading progress as JSON events", func() {
+			progress := make(chan engine.Progress, 2)
+			progress <- mockProgress{}
+			progress <- mockProgress{}
+			close(progress)
+			Expect(ui.Loading("some-message", progress)).To(Succeed())
+			Expect(out).To(gbytes.Say(`"message":"some-message","status":"some-progress"`))
+			Expect(out).NotTo(gbytes.Say("some-progress"))
+		})
+
+		It("should output each line of container logs as a JSON event", func() {
+			logs := ui.Logs("some-source")
+			io.WriteString(logs, "some line\nsome ")
+			io.WriteString(logs, "other line\n")
+			Expect(out).To(gbytes.Say(`"line":"some line","source":"some-source"`))
+			Expect(out).To(gbytes.Say(`"line":"some other line","source":"some-source"`))
+		})
+	})
 })
 
 type mockProgress struct {